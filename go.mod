@@ -6,6 +6,7 @@ require (
 	github.com/BurntSushi/toml v1.5.0
 	github.com/DeprecatedLuar/gohelp v0.0.0-00010101000000-000000000000
 	github.com/DeprecatedLuar/yappers-of-linux/lib/satellite v0.0.0-00010101000000-000000000000
+	github.com/godbus/dbus/v5 v5.2.2
 )
 
 require (
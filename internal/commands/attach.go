@@ -0,0 +1,55 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"yappers-of-linux/internal"
+)
+
+// Matches DisplayConfig.STATUS_LINE_WIDTH in the engine
+const statusLineWidth = 20
+
+func Attach() {
+	events, err := internal.Subscribe()
+	if err != nil {
+		exitControlError(err)
+	}
+
+	// Ctrl+C only detaches, the instance keeps running
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
+
+	clearLine := "\r" + strings.Repeat(" ", statusLineWidth) + "\r"
+
+	for {
+		select {
+		case event, ok := <-events:
+			if !ok {
+				fmt.Print(clearLine)
+				fmt.Println("stopped")
+				return
+			}
+			switch event.Type {
+			case "state":
+				fmt.Print(clearLine + event.State)
+			case "loading":
+				fmt.Print(clearLine + event.Text + "...")
+			case "warning":
+				fmt.Printf("%swarning: %s\n", clearLine, event.Text)
+			case "transcription":
+				if event.App != "" {
+					fmt.Printf("%s[%s] %s\n\n", clearLine, event.App, event.Text)
+				} else {
+					fmt.Printf("%s%s\n\n", clearLine, event.Text)
+				}
+			}
+		case <-sigChan:
+			fmt.Print(clearLine)
+			return
+		}
+	}
+}
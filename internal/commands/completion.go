@@ -0,0 +1,220 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"yappers-of-linux/internal"
+)
+
+// completeCommand is what the completion scripts call back into:
+// `yap __complete <words after yap>`, the last one being the word under the
+// cursor. Candidates are printed one per line.
+const completeCommand = "__complete"
+
+const bashCompletion = `# bash completion for yap
+_yap() {
+    local IFS=$'\n'
+    COMPREPLY=($(yap __complete "${COMP_WORDS[@]:1:COMP_CWORD}" 2>/dev/null))
+}
+complete -o default -F _yap yap
+`
+
+const zshCompletion = `#compdef yap
+_yap() {
+    local -a candidates
+    candidates=(${(f)"$(yap __complete "${(@)words[2,CURRENT]}" 2>/dev/null)"})
+    if (( ${#candidates} )); then
+        compadd -a candidates
+    else
+        _files
+    fi
+}
+if [ "$funcstack[1]" = "_yap" ]; then
+    _yap "$@"
+else
+    compdef _yap yap
+fi
+`
+
+const fishCompletion = `# fish completion for yap
+function __yap_complete
+    set -l words (commandline -opc)
+    yap __complete $words[2..-1] (commandline -ct | string collect -a) 2>/dev/null
+end
+complete -c yap -f -a '(__yap_complete)'
+complete -c yap -n '__fish_seen_subcommand_from transcribe' -F
+`
+
+func completionShells() []string { return []string{"bash", "zsh", "fish"} }
+
+// Completion implements `yap completion bash|zsh|fish`.
+func Completion(args []string) {
+	if len(args) != 1 {
+		fmt.Fprintln(os.Stderr, "usage: yap completion bash|zsh|fish")
+		os.Exit(1)
+	}
+
+	switch args[0] {
+	case "bash":
+		fmt.Print(bashCompletion)
+	case "zsh":
+		fmt.Print(zshCompletion)
+	case "fish":
+		fmt.Print(fishCompletion)
+	default:
+		fmt.Fprintf(os.Stderr, "unsupported shell: %s (bash, zsh, fish)\n", args[0])
+		os.Exit(1)
+	}
+}
+
+// complete prints the candidates for the last of words, read off yapCommands.
+func complete(words []string) {
+	if len(words) == 0 {
+		return
+	}
+	cur := words[len(words)-1]
+	candidates := completions(words[:len(words)-1], cur)
+	if cur == "=" {
+		// bash, right after --flag=
+		cur = ""
+	}
+	for _, candidate := range candidates {
+		if strings.HasPrefix(candidate, cur) {
+			fmt.Println(candidate)
+		}
+	}
+}
+
+func completions(before []string, cur string) []string {
+	commands := yapCommands()
+	if len(before) == 0 {
+		return commandNames(commands)
+	}
+
+	cmd := lookupCommand(commands, before[0])
+	if cmd == nil || cmd.hidden {
+		return nil
+	}
+	rest := before[1:]
+	for len(rest) > 0 {
+		sub := lookupCommand(cmd.sub, rest[0])
+		if sub == nil {
+			break
+		}
+		cmd, rest = sub, rest[1:]
+	}
+
+	// bash splits --model=small into --model, = and small
+	if cur == "=" && len(rest) > 0 {
+		return flagValues(cmd, rest[len(rest)-1], "")
+	}
+	if len(rest) > 1 && rest[len(rest)-1] == "=" {
+		return flagValues(cmd, rest[len(rest)-2], "")
+	}
+	if name, _, ok := strings.Cut(cur, "="); ok && isFlag(name) {
+		return flagValues(cmd, name, name+"=")
+	}
+	if len(rest) > 0 && isFlag(rest[len(rest)-1]) {
+		if f := findFlag(cmd.flags, rest[len(rest)-1]); f != nil && f.arg != "" && !f.optional {
+			return flagValues(cmd, f.name(), "")
+		}
+	}
+
+	if isFlag(cur) || cur == "-" {
+		var names []string
+		for _, f := range cmd.flags {
+			names = append(names, f.names...)
+		}
+		return append(names, "--help")
+	}
+
+	p, _ := parseFlags(cmd.flags, rest)
+	var candidates []string
+	if len(p.args) == 0 {
+		candidates = commandNames(cmd.sub)
+		if cmd.args != nil {
+			candidates = append(candidates, cmd.args()...)
+		}
+	}
+	return candidates
+}
+
+// commandNames are the names and aliases a user would type, for completion.
+func commandNames(commands []command) []string {
+	var names []string
+	for _, cmd := range commands {
+		if cmd.hidden {
+			continue
+		}
+		for _, name := range cmd.names {
+			if !strings.HasPrefix(name, "-") {
+				names = append(names, name)
+			}
+		}
+	}
+	return names
+}
+
+// flagValues returns the candidates for the value of a flag, each with prefix.
+func flagValues(cmd *command, name, prefix string) []string {
+	f := findFlag(cmd.flags, name)
+	if f == nil || f.values == nil {
+		return nil
+	}
+	var values []string
+	for _, value := range f.values() {
+		values = append(values, prefix+value)
+	}
+	return values
+}
+
+// completeModels offers the downloaded models yap accepts, or all of them
+// before any are.
+func completeModels() []string {
+	var models []string
+	for _, model := range installedModels() {
+		if internal.IsOneOf(model, internal.Models) {
+			models = append(models, model)
+		}
+	}
+	if len(models) == 0 {
+		return internal.Models
+	}
+	return models
+}
+
+func completeProfiles() []string {
+	return internal.LoadConfig().ProfileNames()
+}
+
+func devices() []string { return internal.Devices }
+
+func notificationValues() []string {
+	return append([]string{"false", "urgent"}, internal.NotificationEvents...)
+}
+
+func typingBackendValues() []string { return internal.TypingBackendNames() }
+
+func configKeys() []string { return internal.ConfigKeys() }
+
+// liveSettings are what `yap set` can switch.
+func liveSettings() []string {
+	return []string{"model", "language", "device", "fast", "typing"}
+}
+
+// helpTopicNames are what `yap help` takes: its topics, then the commands.
+func helpTopicNames() []string {
+	var names []string
+	for _, topic := range helpTopics() {
+		names = append(names, topic.name)
+	}
+	for _, name := range commandNames(yapCommands()) {
+		if !slices.Contains(names, name) {
+			names = append(names, name)
+		}
+	}
+	return names
+}
@@ -2,16 +2,44 @@ package commands
 
 import (
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
-	"path/filepath"
+	"slices"
 
 	"yappers-of-linux/internal"
 )
 
-func Config() {
-	configDir := filepath.Join(os.Getenv("HOME"), ".config", "yappers-of-linux")
-	configFile := filepath.Join(configDir, "config.toml")
+const configUsage = "usage: yap config [check | migrate [--dry-run] | show [--effective] | list | get KEY | set KEY VALUE | unset KEY] [--profile NAME]"
+
+// configProfileFlags select a profile for list, get, set and unset.
+var configProfileFlags = []flagSpec{
+	{names: []string{"--profile", "-p"}, arg: "NAME", values: completeProfiles, help: "Work on [profiles.NAME] instead of the top level"},
+}
+
+var migrateFlagSpecs = []flagSpec{
+	{names: []string{"--dry-run"}, help: "Only show what would change"},
+}
+
+// showFlagSpecs take start options, to show what yap start with them would run with.
+var showFlagSpecs = slices.Concat([]flagSpec{
+	{names: []string{"--effective"}, help: "Print the final settings and where each comes from"},
+}, withoutFlags(startFlagSpecs, "--daemon"))
+
+// Config implements `yap config`, opening config.toml in $EDITOR; the
+// actions are subcommands (see yapCommands).
+func Config(args []string) {
+	if len(args) > 0 {
+		fmt.Fprintf(os.Stderr, "unknown config command: %s\n", args[0])
+		fmt.Fprintln(os.Stderr, configUsage)
+		os.Exit(1)
+	}
+
+	configFile, err := internal.GetConfigFile()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to get config directory: %v\n", err)
+		os.Exit(1)
+	}
 
 	if _, err := os.Stat(configFile); os.IsNotExist(err) {
 		if err := internal.SelfHeal(); err != nil {
@@ -35,3 +63,242 @@ func Config() {
 		os.Exit(1)
 	}
 }
+
+// ConfigCheck implements `yap config check`. Warnings alone still exit 0.
+func ConfigCheck() {
+	path, err := internal.GetConfigFile()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to get config directory: %v\n", err)
+		os.Exit(1)
+	}
+
+	issues, err := internal.CheckConfig()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to read %s: %v\n", path, err)
+		os.Exit(1)
+	}
+
+	if len(issues) == 0 {
+		fmt.Printf("%s: ok\n", path)
+		return
+	}
+	printConfigIssues(os.Stdout, path, issues)
+	if internal.HasConfigErrors(issues) {
+		os.Exit(1)
+	}
+}
+
+// preflightConfig validates config.toml before the engine starts. Warnings are
+// printed; errors would otherwise silently fall back to defaults, so they stop the start.
+func preflightConfig() bool {
+	issues, err := internal.CheckConfig()
+	if err != nil || len(issues) == 0 {
+		// Unreadable files are reported by LoadConfig's callers
+		return true
+	}
+
+	path, _ := internal.GetConfigFile()
+	printConfigIssues(os.Stderr, path, issues)
+	if internal.HasConfigErrors(issues) {
+		fmt.Fprintln(os.Stderr, "refusing to start with an invalid config (see yap config check)")
+		return false
+	}
+	return true
+}
+
+func printConfigIssues(w io.Writer, path string, issues []internal.ConfigIssue) {
+	for _, issue := range issues {
+		if issue.Line > 0 {
+			fmt.Fprintf(w, "%s:%d: %s\n", path, issue.Line, issue)
+		} else {
+			fmt.Fprintf(w, "%s: %s\n", path, issue)
+		}
+	}
+}
+
+// ConfigMigrate implements `yap config migrate [--dry-run]`. Commands that set
+// up yap (start, once, ...) migrate on their own; this shows or forces it.
+func ConfigMigrate(args []string) {
+	p := mustParseFlags("config migrate", migrateFlagSpecs, args)
+	if len(p.args) > 0 {
+		fmt.Fprintln(os.Stderr, "usage: yap config migrate [--dry-run]")
+		os.Exit(1)
+	}
+	dryRun := p.has("--dry-run")
+
+	plan, err := internal.PlanConfigMigration()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if plan == nil {
+		fmt.Printf("config.toml is up to date (version %d)\n", internal.ConfigVersion)
+		return
+	}
+
+	for _, step := range plan.Summary {
+		fmt.Println(step)
+	}
+	fmt.Println()
+	fmt.Print(plan.Diff())
+	if dryRun {
+		return
+	}
+
+	backup, err := plan.Apply()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	fmt.Printf("\nmigrated to version %d, previous file saved as %s\n", internal.ConfigVersion, backup)
+}
+
+// ConfigShow implements `yap config show [--effective] [start options]`. Plain
+// show prints config.toml; --effective prints what yap start with the same
+// options would run with, and where each value comes from.
+func ConfigShow(args []string) {
+	p := mustParseFlags("config show", showFlagSpecs, args)
+	effective := p.has("--effective")
+	startArgs := append(p.raw(startFlagSpecs), p.args...)
+
+	if !effective {
+		if len(startArgs) > 0 {
+			fmt.Fprintln(os.Stderr, "start options need --effective")
+			os.Exit(1)
+		}
+		path, err := internal.GetConfigFile()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "failed to get config directory: %v\n", err)
+			os.Exit(1)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		os.Stdout.Write(data)
+		return
+	}
+
+	cfg, err := internal.ReadConfig()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "config.toml: %v\n", err)
+		os.Exit(1)
+	}
+	overrides, _, err := startFlags(startArgs)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "yap config show: %v\n", err)
+		os.Exit(1)
+	}
+	if err := cfg.Resolve(overrides); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	keys := internal.ConfigKeys()
+	width := 0
+	for _, key := range keys {
+		width = max(width, len(key))
+	}
+	for _, key := range keys {
+		value, _ := cfg.Value(key)
+		fmt.Printf("%-*s = %-20s # %s\n", width, key, internal.FormatConfigValue(value), cfg.Source(key))
+	}
+	if err := cfg.Validate(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+// ConfigList implements `yap config list [--profile NAME]`: every setting with
+// its value, defaults included.
+func ConfigList(args []string) {
+	profile, rest := configProfileArg(args)
+	if len(rest) != 0 {
+		fmt.Fprintln(os.Stderr, configUsage)
+		os.Exit(1)
+	}
+
+	cfg := readConfigWithProfile(profile)
+	for _, key := range internal.ConfigKeys() {
+		value, _ := cfg.Value(key)
+		fmt.Printf("%s = %s\n", key, internal.FormatConfigValue(value))
+	}
+}
+
+// ConfigGet implements `yap config get KEY [--profile NAME]`. Strings are
+// printed without quotes, for scripts.
+func ConfigGet(args []string) {
+	profile, rest := configProfileArg(args)
+	if len(rest) != 1 {
+		fmt.Fprintln(os.Stderr, configUsage)
+		os.Exit(1)
+	}
+
+	value, err := readConfigWithProfile(profile).Value(rest[0])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	fmt.Println(value)
+}
+
+// ConfigSet implements `yap config set KEY VALUE [--profile NAME]`.
+func ConfigSet(args []string) {
+	profile, rest := configProfileArg(args)
+	if len(rest) != 2 {
+		fmt.Fprintln(os.Stderr, configUsage)
+		os.Exit(1)
+	}
+
+	warnings, err := internal.SetConfigValue(profile, rest[0], rest[1])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	for _, warning := range warnings {
+		fmt.Fprintln(os.Stderr, warning)
+	}
+	printReloadHint()
+}
+
+// ConfigUnset implements `yap config unset KEY [--profile NAME]`.
+func ConfigUnset(args []string) {
+	profile, rest := configProfileArg(args)
+	if len(rest) != 1 {
+		fmt.Fprintln(os.Stderr, configUsage)
+		os.Exit(1)
+	}
+
+	if err := internal.UnsetConfigValue(profile, rest[0]); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	printReloadHint()
+}
+
+// configProfileArg pulls --profile NAME (or -p NAME) out of args.
+func configProfileArg(args []string) (string, []string) {
+	p := mustParseFlags("config", configProfileFlags, args)
+	profile, _ := p.value("--profile")
+	return profile, p.args
+}
+
+func readConfigWithProfile(profile string) *internal.Config {
+	cfg, err := internal.ReadConfig()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "config.toml: %v\n", err)
+		os.Exit(1)
+	}
+	if err := cfg.ApplyProfile(profile); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	return cfg
+}
+
+func printReloadHint() {
+	if internal.InstanceRunning() {
+		fmt.Println("run 'yap reload' to apply it to the running instance")
+	}
+}
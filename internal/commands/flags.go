@@ -0,0 +1,213 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/DeprecatedLuar/gohelp"
+)
+
+// flagSpec declares one option of a command. The same declaration drives
+// parsing, help and shell completion.
+type flagSpec struct {
+	names    []string // "--model", then aliases ("--lang", "-d"); the first is canonical
+	arg      string   // value placeholder for help, "" for switches
+	optional bool     // the value may be left out (--tcp [PORT]), meaning def
+	def      string
+	help     string
+	values   func() []string // completion candidates for the value
+
+	// Start options override a config setting; switches set it to value
+	setting string
+	value   string
+}
+
+func (f *flagSpec) name() string { return f.names[0] }
+
+// usage renders the flag for help, e.g. "--tcp [PORT]" or "--daemon, -d".
+func (f *flagSpec) usage() string {
+	s := strings.Join(f.names, ", ")
+	switch {
+	case f.arg == "":
+	case f.optional:
+		s += " [" + f.arg + "]"
+	default:
+		s += " " + f.arg
+	}
+	return s
+}
+
+// parsedFlag is one flag given on the command line.
+type parsedFlag struct {
+	spec  *flagSpec
+	name  string   // as typed, e.g. "--lang"
+	value string   // the switch's value for switches
+	raw   []string // the arguments it came from
+}
+
+// parsedArgs is a command line split into flags, in order, and positional arguments.
+type parsedArgs struct {
+	flags []parsedFlag
+	args  []string
+}
+
+// has reports whether the flag with the given canonical name was given.
+func (p parsedArgs) has(name string) bool {
+	_, ok := p.value(name)
+	return ok
+}
+
+// value returns the value of the last occurrence of a flag.
+func (p parsedArgs) value(name string) (string, bool) {
+	for i := len(p.flags) - 1; i >= 0; i-- {
+		if p.flags[i].spec.name() == name {
+			return p.flags[i].value, true
+		}
+	}
+	return "", false
+}
+
+// raw returns the arguments of the flags matching keep, for passing on.
+func (p parsedArgs) raw(keep []flagSpec) []string {
+	var args []string
+	for _, f := range p.flags {
+		if findFlag(keep, f.spec.name()) != nil {
+			args = append(args, f.raw...)
+		}
+	}
+	return args
+}
+
+// parseFlags accepts --flag value, --flag=value and switches anywhere among
+// the positional arguments. "--" ends the flags; "-" and negative numbers are
+// positional. Unknown flags and missing values are errors.
+func parseFlags(specs []flagSpec, args []string) (parsedArgs, error) {
+	var p parsedArgs
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--" {
+			p.args = append(p.args, args[i+1:]...)
+			break
+		}
+		if !isFlag(arg) {
+			p.args = append(p.args, arg)
+			continue
+		}
+
+		name, value, hasValue := strings.Cut(arg, "=")
+		spec := findFlag(specs, name)
+		if spec == nil {
+			return p, unknownFlagError(specs, name)
+		}
+		f := parsedFlag{spec: spec, name: name, value: value, raw: []string{arg}}
+
+		switch {
+		case spec.arg == "":
+			if hasValue {
+				return p, fmt.Errorf("%s does not take a value", name)
+			}
+			f.value = spec.value
+		case hasValue:
+		case spec.optional:
+			f.value = spec.def
+			if i+1 < len(args) && !isFlag(args[i+1]) && args[i+1] != "--" {
+				f.value = args[i+1]
+				f.raw = append(f.raw, args[i+1])
+				i++
+			}
+		case i+1 < len(args):
+			f.value = args[i+1]
+			f.raw = append(f.raw, args[i+1])
+			i++
+		default:
+			return p, fmt.Errorf("%s needs a value (%s)", name, spec.usage())
+		}
+
+		p.flags = append(p.flags, f)
+	}
+	return p, nil
+}
+
+// mustParseFlags is parseFlags for command handlers: errors end the program.
+func mustParseFlags(command string, specs []flagSpec, args []string) parsedArgs {
+	p, err := parseFlags(specs, args)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "yap %s: %v\n", command, err)
+		fmt.Fprintf(os.Stderr, "run 'yap help' for usage\n")
+		os.Exit(1)
+	}
+	return p
+}
+
+func isFlag(arg string) bool {
+	if len(arg) < 2 || arg[0] != '-' {
+		return false
+	}
+	// -0.7 is a value
+	return !strings.ContainsRune("0123456789.", rune(arg[1]))
+}
+
+func findFlag(specs []flagSpec, name string) *flagSpec {
+	for i := range specs {
+		if slices.Contains(specs[i].names, name) {
+			return &specs[i]
+		}
+	}
+	return nil
+}
+
+// withoutFlags returns specs minus the flags with the given canonical names.
+func withoutFlags(specs []flagSpec, names ...string) []flagSpec {
+	var kept []flagSpec
+	for _, f := range specs {
+		if !slices.Contains(names, f.name()) {
+			kept = append(kept, f)
+		}
+	}
+	return kept
+}
+
+func unknownFlagError(specs []flagSpec, name string) error {
+	best, bestDistance := "", 3 // suggest only close misspellings
+	for _, f := range specs {
+		for _, n := range f.names {
+			if d := editDistance(name, n); d < bestDistance {
+				best, bestDistance = n, d
+			}
+		}
+	}
+	if best != "" {
+		return fmt.Errorf("unknown option %s (did you mean %s?)", name, best)
+	}
+	return fmt.Errorf("unknown option %s", name)
+}
+
+// editDistance is the Levenshtein distance between two short strings.
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur := make([]int, len(b)+1)
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev = cur
+	}
+	return prev[len(b)]
+}
+
+// printFlags lists flags in help.
+func printFlags(specs []flagSpec) {
+	for i := range specs {
+		gohelp.Item(specs[i].usage(), specs[i].help)
+	}
+}
@@ -4,14 +4,42 @@ import (
 	"fmt"
 
 	"github.com/DeprecatedLuar/gohelp"
+
+	"yappers-of-linux/internal"
 )
 
+// helpTopic is a `yap help <topic>` page, for what a command's --help is too
+// short to explain.
+type helpTopic struct {
+	name    string
+	summary string
+	show    func()
+}
+
+// helpTopics lists the topics in the order the main help shows them. A topic
+// named after a command replaces its --help under yap help.
+func helpTopics() []helpTopic {
+	return []helpTopic{
+		{"config", "Configuration file syntax", showConfigHelp},
+		{"status", "Status exit codes for scripts", showStatusHelp},
+		{"service", "Running on login with systemd", showServiceHelp},
+		{"once", "One-shot dictation for scripts", showOnceHelp},
+		{"transcribe", "Transcribing audio files", showTranscribeHelp},
+		{"completion", "Shell completion", showCompletionHelp},
+		{"typing", "Typing backends for each desktop", showTypingHelp},
+	}
+}
+
 func Help(args []string) {
-	// Handle subcommands
 	if len(args) > 0 {
-		switch args[0] {
-		case "config":
-			showConfigHelp()
+		for _, topic := range helpTopics() {
+			if topic.name == args[0] {
+				topic.show()
+				return
+			}
+		}
+		if cmd := lookupCommand(yapCommands(), args[0]); cmd != nil && !cmd.hidden {
+			printCommandHelp(cmd.name(), cmd)
 			return
 		}
 	}
@@ -25,25 +53,10 @@ func Help(args []string) {
 	gohelp.Item("Edit:", "yap config (opens in $EDITOR)")
 
 	gohelp.PrintHeader("Commands")
-	gohelp.Item("start [options]", "Start voice typing (default: --model tiny)")
-	gohelp.Item("toggle [options]", "Smart pause/resume/start")
-	gohelp.Item("pause", "Pause listening")
-	gohelp.Item("resume", "Resume listening")
-	gohelp.Item("stop (kill)", "Stop voice typing")
-	gohelp.Item("output (log, cat, show)", "View output file contents")
-	gohelp.Item("models", "Show installed models")
-	gohelp.Item("config", "Open config file in $EDITOR")
-	gohelp.Item("version", "Show version and check for updates")
-	gohelp.Item("update [--force]", "Update to latest version")
+	printCommands("", yapCommands(), true)
 
 	gohelp.PrintHeader("Options")
-	gohelp.Item("--model X", "Model size: tiny, base, small, medium, large")
-	gohelp.Item("--cpu / --gpu", "Device selection")
-	gohelp.Item("--language X", "Language code (default: en)")
-	gohelp.Item("--lang X", "Short alias for --language")
-	gohelp.Item("--tcp [PORT]", "Enable TCP server (default port: 12322)")
-	gohelp.Item("--fast", "Use fast mode (int8, less accurate but faster)")
-	gohelp.Item("--no-typing", "Disable keyboard typing (only print to terminal)")
+	printFlags(startFlagSpecs)
 
 	gohelp.PrintHeader("Modes")
 	gohelp.Item("default", "Accurate mode (float32, better quality)")
@@ -52,24 +65,176 @@ func Help(args []string) {
 	gohelp.Paragraph("Models automatically download on first use")
 
 	gohelp.PrintHeader("For more help")
-	gohelp.Item("yap help config", "Configuration file syntax")
+	for _, topic := range helpTopics() {
+		gohelp.Item("yap help "+topic.name, topic.summary)
+	}
+	gohelp.Item("yap <command> --help", "Usage and options of one command")
+}
+
+func showTypingHelp() {
+	gohelp.PrintHeader("Typing Backends")
+	gohelp.Paragraph("Text is typed into the focused window by the first backend of typing_backend that works. With \"auto\" that is wtype on Wayland or xdotool on X11, then ydotool, dotool and the built-in uinput keyboard, skipping any that is not set up. A backend that fails (wtype on GNOME or KDE, which lack the virtual keyboard protocol) hands over to the next, which is then tried first.")
+
+	gohelp.PrintHeader("Backends")
+	for _, b := range internal.TypingBackends {
+		gohelp.Item(b.Name, b.Summary)
+	}
+
+	gohelp.PrintHeader("Examples")
+	gohelp.Item(`"auto"`, "The session's tool, then the rest")
+	gohelp.Item(`"ydotool,uinput"`, "GNOME or KDE on Wayland")
+	gohelp.Item("yap typing", "Which backends can work here, and why not")
+	gohelp.Item("yap typing test", "Type a sample line into the terminal with each")
+}
+
+func showCompletionHelp() {
+	gohelp.PrintHeader("Shell Completion")
+	gohelp.Paragraph("yap completion prints a script that completes commands, options, setting names, installed models and config profiles. Load it from your shell's startup file.")
+	gohelp.Item(`eval "$(yap completion bash)"`, "bash, in ~/.bashrc")
+	gohelp.Item(`eval "$(yap completion zsh)"`, "zsh, in ~/.zshrc after compinit")
+	gohelp.Item("yap completion fish | source", "fish, in ~/.config/fish/config.fish")
+}
+
+func showTranscribeHelp() {
+	gohelp.PrintHeader("File Transcription")
+	gohelp.Paragraph("Transcribes a WAV, FLAC or OGG recording (or stdin with -), resampled to 16 kHz, using the model, device and fast mode from config and start options. Segments keep their timestamps and a 0-1 confidence score.")
+
+	gohelp.PrintHeader("Options")
+	printFlags(transcribeOptions)
+	gohelp.Item("--model, --language, ...", "Same as start")
+
+	gohelp.PrintHeader("Examples")
+	gohelp.Item("yap transcribe memo.ogg", "Plain text to stdout")
+	gohelp.Item("yap transcribe meeting.flac -o meeting.srt", "Subtitles")
+	gohelp.Item("arecord -d 10 | yap transcribe - -f json", "From stdin")
+}
+
+func showOnceHelp() {
+	gohelp.PrintHeader("One-shot Dictation")
+	gohelp.Paragraph("Waits for one utterance, prints the text to stdout and exits. If an instance is running its loaded model is borrowed and nothing is typed into the focused window; a paused instance is an error, as are start options, which only apply to the temporary engine started when no instance runs.")
+
+	gohelp.PrintHeader("Options")
+	printFlags(onceOptions)
+	gohelp.Item("--model, --language, ...", "Same as start")
+
+	gohelp.PrintHeader("Exit Codes")
+	gohelp.Item("0", "Text printed (or typed)")
+	gohelp.Item("1", "Error")
+	gohelp.Item("2", "Timed out waiting for speech")
+	gohelp.Item("3", "No speech recognized")
+
+	gohelp.PrintHeader("Examples")
+	gohelp.Item(`git commit -m "$(yap once)"`, "Dictate a commit message")
+	gohelp.Item("yap once | rofi -dmenu", "Feed a prompt")
+}
+
+func showServiceHelp() {
+	gohelp.PrintHeader("Systemd Service")
+	gohelp.Paragraph("Installs a systemd user unit that starts and stops yap with your graphical session (graphical-session.target, which GNOME, KDE and session managers like uwsm activate). The unit runs the current binary and captures the session environment (XDG_SESSION_TYPE, WAYLAND_DISPLAY, DISPLAY, ...) the typing tools need, so run install from inside your graphical session. Reinstall after switching sessions or compositors, or raising stop_timeout (the unit gives yap that long to stop).")
+
+	gohelp.PrintHeader("Actions")
+	printCommands("", lookupCommand(yapCommands(), "service").sub, false)
+
+	gohelp.PrintHeader("Examples")
+	gohelp.Item("yap service install", "Start with config.toml settings")
+	gohelp.Item("yap service install --model small", "Pass start options to the service")
+	gohelp.Item("journalctl --user -u yap", "Service logs")
+}
+
+func showStatusHelp() {
+	gohelp.PrintHeader("Status")
+	gohelp.Paragraph("Reports state, model, device, language, pid, uptime, transcription count and engine tuning ([audio], [vad], [transcription]) of the running instance. Use --json for scripts.")
+
+	gohelp.PrintHeader("Exit Codes")
+	gohelp.Item("0", "Running (ready, listening or processing)")
+	gohelp.Item("1", "Error talking to the instance")
+	gohelp.Item("2", "Running but paused")
+	gohelp.Item("3", "Not running")
+	gohelp.Item("4", "Still initializing (loading model)")
 }
 
 func showConfigHelp() {
 	gohelp.PrintHeader("Configuration File")
 	gohelp.Item("Location:", "~/.config/yappers-of-linux/config.toml")
 	gohelp.Item("Edit:", "yap config (opens in $EDITOR)")
+	gohelp.Item("Check:", "yap config check (unknown keys, bad values, with line numbers)")
+	gohelp.Item("Upgrade:", "yap config migrate [--dry-run] (start does it on its own, keeping a .bak)")
+
+	gohelp.PrintHeader("Precedence")
+	gohelp.Paragraph("Each setting comes from the first of: a start flag, a YAP_<SETTING> environment variable (YAP_MODEL, YAP_TCP_PORT, YAP_PROFILE, ...), the selected profile, config.toml, the built-in default. yap config show --effective [start options] prints the result with the source of every value.")
+	gohelp.Item("YAP_MODEL=small yap start", "Override one setting for this run")
+	gohelp.Item("yap config show --effective --fast", "What yap start --fast would use")
+
+	gohelp.PrintHeader("From Scripts")
+	gohelp.Paragraph("config set and unset edit config.toml in place, keeping comments and key order. Values are checked like yap config check before anything is written. Add --profile NAME to read or change a [profiles.NAME] table instead.")
+	gohelp.Item("yap config list", "Every setting, defaults included")
+	gohelp.Item("yap config get model", "One value (strings unquoted)")
+	gohelp.Item("yap config set model small", "Change a setting")
+	gohelp.Item("yap config set language es --profile notes", "Change a profile")
+	gohelp.Item("yap config unset timeout", "Back to the default")
 
 	gohelp.PrintHeader("Notifications")
-	gohelp.Paragraph("Control desktop notifications with comma-separated events and optional 'urgent' modifier. Events: start (yapping started), pause, stop. Urgent makes notifications persistent and ignores Do Not Disturb mode.")
+	gohelp.Paragraph("Control desktop notifications with comma-separated events, each with an optional urgency (event:low, event:normal or event:critical). Events: start (yapping started), pause, stop, transcribed (a preview of the text), error (microphone lost, typing tool or model load failed), timeout (paused for lack of output) and loading (model download and load progress). Critical makes notifications persistent and ignores Do Not Disturb mode; a bare 'urgent' makes every event without an urgency critical. Each event updates the same bubble, which offers Pause, Resume and Stop buttons where the notification daemon supports actions (notify-send is used when there is no session bus).")
 
 	gohelp.PrintHeader("Examples")
-	gohelp.Item(`"start,pause,stop"`, "All events, normal priority")
-	gohelp.Item(`"start,pause,stop,urgent"`, "All events, urgent (persistent, ignores DND)")
-	gohelp.Item(`"start"`, "Only when yapping starts")
-	gohelp.Item(`"urgent"`, `Shorthand for "start,urgent"`)
+	gohelp.Item(`"start,pause,stop"`, "Start, pause and stop, normal urgency")
+	gohelp.Item(`"start,stop,error:critical"`, "Errors persistent, ignoring DND")
+	gohelp.Item(`"transcribed:low,timeout"`, "Text previews, quietly, and timeouts")
+	gohelp.Item(`"start,pause,stop,urgent"`, "All three critical")
+	gohelp.Item(`"urgent"`, `Shorthand for "start:critical"`)
 	gohelp.Item(`"false" / ""`, "Disabled (false or empty string)")
 
+	gohelp.PrintHeader("Sounds")
+	gohelp.Paragraph("The [sounds] table plays short cues with pw-play, paplay or aplay, whichever is installed, for when the terminal is hidden and notifications are off. Each event can be switched off on its own.")
+	gohelp.Item("enabled = true", "Turn cues on (default: false)")
+	gohelp.Item("volume = 0.5", "0-1")
+	gohelp.Item("ready / pause / stop", "Listening started or resumed, paused, stopped")
+	gohelp.Item("captured", "An utterance ended and is being transcribed")
+	gohelp.Item("error", "The engine crashed")
+
+	gohelp.PrintHeader("Profiles")
+	gohelp.Paragraph("A [profiles.<name>] table overrides any top-level setting when selected with --profile <name> (start or toggle). Set profile = \"<name>\" at the top level to pick one by default. Command-line flags still win over the profile.")
+	gohelp.Item("[profiles.notes]", "Start a profile named notes")
+	gohelp.Item(`language = "es"`, "Override the language")
+	gohelp.Item("enable_typing = false", "Only print, don't type")
+	gohelp.Item("yap start --profile notes", "Use it")
+
+	gohelp.PrintHeader("Apps")
+	gohelp.Paragraph("[[app]] tables override typing, trailing_space, press_enter and language for utterances dictated into a matching window. match is a case-insensitive glob against the window class (X11, XWayland) or app_id (Wayland), found with hyprctl, swaymsg, KWin scripting or xdotool when the utterance ends; the first matching rule wins. A rule can turn typing off but not on. yap attach shows the window class next to texts a rule applied to.")
+	gohelp.Item("[[app]]", "Start a rule")
+	gohelp.Item(`match = "kitty"`, "Terminal: kitty")
+	gohelp.Item("trailing_space = false", "No space after the text (default: true)")
+	gohelp.Item("press_enter = true", "Press Enter after the text (default: false)")
+	gohelp.Item(`language = "es"`, "Transcribe in another language")
+	gohelp.Item("enable_typing = false", "Only print, don't type")
+	gohelp.Item("xprop WM_CLASS / swaymsg -t get_tree / hyprctl activewindow", "Find a window's class")
+
+	gohelp.PrintHeader("Push-to-talk")
+	gohelp.Paragraph("With capture_mode = \"ptt\" nothing is recorded until yap hold, and everything until yap release is transcribed as one utterance, pauses included. Bind both to the press and release of one key in your compositor. Holds longer than two minutes are released automatically.")
+	gohelp.Item(`capture_mode = "ptt"`, "Enable push-to-talk (default: \"vad\")")
+	gohelp.Item("bindsym --no-repeat F9 exec yap hold", "sway: start on press")
+	gohelp.Item("bindsym --release F9 exec yap release", "sway: transcribe on release")
+
+	gohelp.PrintHeader("Idle")
+	gohelp.Paragraph("timeout pauses after that many seconds without a transcription (never in push-to-talk mode). idle_stop stops the engine after that many minutes paused, freeing the model's memory; yap start brings it back.")
+	gohelp.Item("timeout = 30", "Auto-pause after 30s without output (0 = disabled)")
+	gohelp.Item("idle_stop = 15", "Stop after 15 minutes paused (0 = disabled)")
+
+	gohelp.PrintHeader("Tuning")
+	gohelp.Paragraph("The [audio], [vad] and [transcription] tables tune speech detection and decoding. yap reload applies them without restarting, yap status shows the values in effect, and profiles can override them in [profiles.<name>.audio] and so on. Set them from scripts with dotted keys (yap config set audio.silence_duration 1.2) or YAP_AUDIO_SILENCE_DURATION.")
+	gohelp.Item("audio.silence_duration = 0.8", "Seconds of silence that end an utterance")
+	gohelp.Item("audio.pre_buffer = 1.5", "Seconds kept from before speech was detected")
+	gohelp.Item("vad.aggressiveness = 3", "0-3, lower if quiet speech gets cut off")
+	gohelp.Item("transcription.beam_size = 5", "1-20, lower is faster")
+	gohelp.Item("transcription.min_confidence = -0.7", "Drop less likely segments (log-probability, 0 = certain)")
+	gohelp.Item("transcription.min_audio_duration = 0.7", "Ignore shorter utterances (seconds)")
+
+	gohelp.PrintHeader("Reloading")
+	gohelp.Paragraph("yap reload (or SIGHUP to a daemon/service) re-reads the config and applies it without restarting. Model, device and fast mode changes load the new model while the old one keeps listening. tcp_port only changes on restart. yap set changes a single setting until the next restart or reload, without touching the file.")
+	gohelp.Item("yap set model small", "Switch model")
+	gohelp.Item("yap set language es", "Switch language (auto to detect)")
+	gohelp.Item("systemctl --user reload yap", "Reload the service")
+
 	gohelp.PrintHeader("Output File")
 	gohelp.Paragraph("Write transcriptions to output.txt for piping to other scripts or automation. File is ephemeral - deleted on each start for fresh sessions. Location: ~/.config/yappers-of-linux/output.txt")
 	gohelp.Item("output_file = true", "Enable file output")
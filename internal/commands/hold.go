@@ -0,0 +1,43 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+
+	"yappers-of-linux/internal"
+)
+
+// pushToTalk starts (hold) or ends (release) a push-to-talk utterance.
+// The engine answers as soon as recording starts or stops; transcription follows.
+func (s *supervisor) pushToTalk(cmd string) internal.ControlResponse {
+	if _, err := s.engineRequest(cmd, nil, ackTimeout); err != nil {
+		return internal.ControlResponse{State: s.currentState(), Error: err.Error()}
+	}
+	return internal.ControlResponse{OK: true, State: s.currentState()}
+}
+
+// Hold starts recording immediately (capture_mode = "ptt").
+func Hold() {
+	resp, err := internal.SendControl("hold")
+	if err != nil {
+		exitControlError(err)
+	}
+
+	if !resp.OK {
+		fmt.Fprintf(os.Stderr, "failed to hold: %s\n", resp.Error)
+		os.Exit(1)
+	}
+}
+
+// Release ends the held utterance and transcribes it.
+func Release() {
+	resp, err := internal.SendControl("release")
+	if err != nil {
+		exitControlError(err)
+	}
+
+	if !resp.OK {
+		fmt.Fprintf(os.Stderr, "failed to release: %s\n", resp.Error)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,59 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"yappers-of-linux/internal"
+)
+
+// markActive restarts the idle clock. Requires s.mu.
+func (s *supervisor) markActive() {
+	s.lastOutput = time.Now()
+}
+
+// checkIdle applies the idle policy once per watchdog tick: `timeout` pauses
+// after that many seconds without output, `idle_stop` stops the engine after
+// that many minutes paused. It reports whether the engine should be stopped.
+func (s *supervisor) checkIdle() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch s.state {
+	case "ready":
+		// Idle is normal between push-to-talk holds
+		if s.cfg.Timeout <= 0 || s.cfg.CaptureMode == "ptt" || s.idlePausing {
+			return false
+		}
+		idle := time.Duration(s.cfg.Timeout) * time.Second
+		if time.Since(s.lastOutput) < idle {
+			return false
+		}
+		s.idlePausing = true
+		go s.idlePause(idle)
+		return false
+
+	case "paused":
+		return s.cfg.IdleStop > 0 && time.Since(s.stateSince) >= time.Duration(s.cfg.IdleStop)*time.Minute
+
+	default:
+		return false
+	}
+}
+
+// idlePause pauses through the regular control path, so it queues behind
+// other requests and fires the usual notification.
+func (s *supervisor) idlePause(idle time.Duration) {
+	fmt.Fprintf(os.Stderr, "no output for %s, pausing\n", idle)
+	resp := s.handle(internal.ControlRequest{Cmd: "pause"})
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.idlePausing = false
+	if !resp.OK {
+		// Try again after another full timeout rather than every tick
+		fmt.Fprintf(os.Stderr, "auto-pause failed: %s\n", resp.Error)
+		s.markActive()
+	}
+}
@@ -0,0 +1,71 @@
+package commands
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"yappers-of-linux/internal"
+)
+
+var logsFlagSpecs = []flagSpec{
+	{names: []string{"--follow", "-f"}, help: "Keep printing new lines"},
+}
+
+func Logs(args []string) {
+	follow := mustParseFlags("logs", logsFlagSpecs, args).has("--follow")
+
+	logPath, err := internal.GetLogFile()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to get log file: %v\n", err)
+		os.Exit(1)
+	}
+
+	f, err := os.Open(logPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			fmt.Fprintln(os.Stderr, "no log yet (logs are written by yap start --daemon)")
+			fmt.Fprintln(os.Stderr, "for the systemd service run: journalctl --user -u yap")
+			os.Exit(1)
+		}
+		fmt.Fprintf(os.Stderr, "failed to read log: %v\n", err)
+		os.Exit(1)
+	}
+	defer f.Close()
+
+	offset, _ := io.Copy(os.Stdout, f)
+	if !follow {
+		return
+	}
+
+	for {
+		time.Sleep(200 * time.Millisecond)
+
+		info, err := os.Stat(logPath)
+		if err != nil {
+			continue
+		}
+
+		// A new daemon session starts a new log: start over from the top.
+		// f is nil while the log could not be reopened.
+		if f == nil || !isFile(f, info) || info.Size() < offset {
+			if f != nil {
+				f.Close()
+			}
+			if f, err = os.Open(logPath); err != nil {
+				continue
+			}
+			offset = 0
+		}
+
+		n, _ := io.Copy(os.Stdout, f)
+		offset += n
+	}
+}
+
+// isFile reports whether the open f is the file info describes.
+func isFile(f *os.File, info os.FileInfo) bool {
+	current, err := f.Stat()
+	return err == nil && os.SameFile(current, info)
+}
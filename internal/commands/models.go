@@ -8,17 +8,25 @@ import (
 )
 
 func Models() {
+	models := installedModels()
+	if len(models) == 0 {
+		fmt.Println("no models installed")
+	} else {
+		fmt.Printf("installed: %s\n", strings.Join(models, ", "))
+	}
+}
+
+// installedModels lists the models in the Hugging Face cache.
+func installedModels() []string {
 	homeDir, err := os.UserHomeDir()
 	if err != nil {
-		fmt.Println("no models installed")
-		return
+		return nil
 	}
 
 	cacheDir := filepath.Join(homeDir, ".cache", "huggingface", "hub")
 	entries, err := os.ReadDir(cacheDir)
 	if err != nil {
-		fmt.Println("no models installed")
-		return
+		return nil
 	}
 
 	var models []string
@@ -28,10 +36,5 @@ func Models() {
 			models = append(models, model)
 		}
 	}
-
-	if len(models) == 0 {
-		fmt.Println("no models installed")
-	} else {
-		fmt.Printf("installed: %s\n", strings.Join(models, ", "))
-	}
+	return models
 }
@@ -0,0 +1,195 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"time"
+
+	"yappers-of-linux/internal"
+)
+
+// Exit codes for `yap once`, besides 0 (text) and 1 (error)
+const (
+	onceTimeout  = 2 // nobody spoke within --timeout
+	onceNoSpeech = 3 // something was recorded but nothing was recognized
+)
+
+const defaultOnceTimeout = 10 // seconds to wait for speech to start
+
+// once lends the running engine for a single utterance. The text goes back to
+// the caller instead of being typed, unless the caller asked for typing.
+func (s *supervisor) once(args []string) internal.ControlResponse {
+	if len(args) == 0 {
+		return internal.ControlResponse{Error: "usage: once <timeout> [--type]"}
+	}
+	timeout, err := strconv.Atoi(args[0])
+	if err != nil || timeout <= 0 {
+		return internal.ControlResponse{Error: "timeout must be a positive number of seconds"}
+	}
+	typeText := len(args) > 1 && args[1] == "--type"
+
+	if state := s.currentState(); state == "paused" {
+		// The user closed the mic; borrowing it would reopen it behind their back
+		return internal.ControlResponse{State: state, Error: "instance is paused"}
+	}
+
+	wait := time.Duration(timeout)*time.Second + processingTimeout
+	reply, err := s.engineRequest("once", map[string]any{"timeout": timeout, "type": typeText}, wait)
+	if err != nil {
+		return internal.ControlResponse{State: s.currentState(), Error: err.Error(), Reason: reply.Reason}
+	}
+	return internal.ControlResponse{OK: true, State: s.currentState(), Message: reply.Text}
+}
+
+// onceFlagSpecs are the options of once. Its --timeout is how long to wait
+// for speech, not the auto-pause setting; there is nothing to daemonize.
+var (
+	onceOptions = []flagSpec{
+		{names: []string{"--timeout"}, arg: "N", help: "Seconds to wait for speech to start (default: 10)"},
+		{names: []string{"--type"}, help: "Type the text instead of printing it"},
+	}
+	onceFlagSpecs = slices.Concat(onceOptions, withoutFlags(startFlagSpecs, "--timeout", "--daemon"))
+)
+
+// Once implements `yap once [--type] [--timeout N] [start options]`.
+func Once(args []string) {
+	p := mustParseFlags("once", onceFlagSpecs, args)
+	if len(p.args) > 0 {
+		fmt.Fprintf(os.Stderr, "unexpected argument: %s\n", p.args[0])
+		os.Exit(1)
+	}
+
+	timeout := defaultOnceTimeout
+	if value, ok := p.value("--timeout"); ok {
+		n, err := strconv.Atoi(value)
+		if err != nil || n <= 0 {
+			fmt.Fprintln(os.Stderr, "--timeout must be a positive number of seconds")
+			os.Exit(1)
+		}
+		timeout = n
+	}
+	typeText := p.has("--type")
+	startArgs := p.raw(withoutFlags(startFlagSpecs, "--timeout", "--daemon"))
+
+	request := []string{strconv.Itoa(timeout)}
+	if typeText {
+		request = append(request, "--type")
+	}
+
+	if len(startArgs) > 0 && internal.InstanceRunning() {
+		// A borrowed engine keeps its own settings
+		fmt.Fprintln(os.Stderr, "start options only apply without a running instance (yap stop first, or drop them)")
+		os.Exit(1)
+	}
+
+	// Borrow the running instance's loaded model when there is one
+	wait := time.Duration(timeout)*time.Second + processingTimeout + ackTimeout
+	resp, err := internal.SendControlTimeout(wait, "once", request...)
+	switch {
+	case err == internal.ErrNotRunning:
+		onceLocal(startArgs, timeout, typeText)
+	case err != nil:
+		exitControlError(err)
+	case !resp.OK && resp.State == "paused":
+		// Not behind the user's back with an engine of our own either
+		fmt.Fprintln(os.Stderr, "the running instance is paused (yap resume first)")
+		os.Exit(1)
+	case !resp.OK && resp.State == "initializing":
+		onceLocal(startArgs, timeout, typeText)
+	default:
+		finishOnce(internal.ReplyEvent{OK: resp.OK, Text: resp.Message, Error: resp.Error, Reason: resp.Reason}, typeText)
+	}
+}
+
+// onceLocal runs a throwaway engine for a single utterance.
+func onceLocal(startArgs []string, timeout int, typeText bool) {
+	if err := internal.SelfHeal(); err != nil {
+		fmt.Fprintf(os.Stderr, "setup failed: %v\n", err)
+		os.Exit(1)
+	}
+
+	cfg := internal.LoadConfig()
+	opts, err := resolveStartOptions(cfg, startArgs)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	// Nothing that outlives or reaches beyond this one utterance
+	opts.enableTyping = typeText
+	opts.tcpPort = ""
+	cfg.OutputFile = false
+
+	if err := opts.resolveTyping(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, "install one, or choose another with typing_backend")
+		os.Exit(1)
+	}
+
+	systemDir, err := internal.GetSystemDir()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to get system directory: %v\n", err)
+		os.Exit(1)
+	}
+
+	venvPython := filepath.Join(systemDir, "venv", "bin", "python")
+	script := filepath.Join(systemDir, "main.py")
+
+	cmd := exec.Command(venvPython, append(engineArgs(script, cfg, opts), "--once", strconv.Itoa(timeout))...)
+	cmd.Env = engineEnv(systemDir)
+	cmd.Stderr = os.Stderr
+
+	events, eventsOut, err := eventPipe(cmd)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	if err := cmd.Start(); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to start engine: %v\n", err)
+		os.Exit(1)
+	}
+	eventsOut.Close()
+
+	var result *internal.ReplyEvent
+	internal.ReadEngineEvents(events, func(event internal.EngineEvent) {
+		switch e := event.(type) {
+		case internal.ReplyEvent:
+			if e.ID == 0 {
+				result = &e
+			}
+		case internal.FatalEvent:
+			fmt.Fprintf(os.Stderr, "engine error: %s\n", e.Message)
+		}
+	})
+	cmd.Wait()
+
+	if result == nil {
+		fmt.Fprintf(os.Stderr, "engine exited without a result (%s)\n", cmd.ProcessState)
+		os.Exit(1)
+	}
+	finishOnce(*result, typeText)
+}
+
+func finishOnce(result internal.ReplyEvent, typeText bool) {
+	if result.OK {
+		if !typeText {
+			fmt.Println(result.Text)
+		}
+		return
+	}
+
+	fmt.Fprintln(os.Stderr, result.Error)
+	switch result.Reason {
+	case "timeout":
+		os.Exit(onceTimeout)
+	case "no_speech":
+		os.Exit(onceNoSpeech)
+	default:
+		os.Exit(1)
+	}
+}
@@ -3,42 +3,184 @@ package commands
 import (
 	"fmt"
 	"os"
+	"slices"
+	"strings"
+
+	"github.com/DeprecatedLuar/gohelp"
 )
 
+// command declares a subcommand. Dispatch, help and shell completion all
+// read this table, so adding a command or flag here is all it takes.
+type command struct {
+	names   []string // "stop", then aliases ("kill")
+	usage   string   // what follows the name in help, e.g. "FILE|-" or "[options]"
+	summary string
+	flags   []flagSpec
+	args    func() []string // completion candidates for positional arguments
+	sub     []command       // e.g. `yap config check`; without a match run gets the args
+	run     func(args []string)
+	hidden  bool // internal, left out of help and completion
+}
+
+func (c *command) name() string { return c.names[0] }
+
+// yapCommands lists the commands in the order help shows them.
+func yapCommands() []command {
+	return []command{
+		{names: []string{"start"}, usage: "[options]", summary: "Start voice typing (default: --model tiny)", flags: startFlagSpecs, run: Start},
+		{names: []string{"toggle"}, usage: "[options]", summary: "Smart pause/resume/start", flags: startFlagSpecs, run: Toggle},
+		{names: []string{"pause"}, summary: "Pause listening", run: noArgs(Pause)},
+		{names: []string{"resume"}, summary: "Resume listening", run: noArgs(Resume)},
+		{names: []string{"stop", "kill"}, summary: "Stop voice typing", run: noArgs(Stop)},
+		{names: []string{"once"}, usage: "[--type] [--timeout N]", summary: "Dictate one utterance to stdout, then exit", flags: onceFlagSpecs, run: Once},
+		{names: []string{"transcribe"}, usage: "FILE|-", summary: "Transcribe a recording to txt, srt, vtt or json", flags: transcribeFlagSpecs, run: Transcribe},
+		{names: []string{"hold"}, summary: "Push-to-talk: record while held (capture_mode = \"ptt\")", run: noArgs(Hold)},
+		{names: []string{"release"}, summary: "Push-to-talk: transcribe what was held", run: noArgs(Release)},
+		{
+			names: []string{"typing"}, summary: "Show which typing backends can work here", run: noArgs(Typing),
+			sub: []command{
+				{names: []string{"test"}, usage: "[--backend X]", summary: "Type a sample line into this terminal with each backend", flags: typingTestFlagSpecs, run: TypingTest},
+			},
+		},
+		{names: []string{"set"}, usage: "<setting> <value>", summary: "Switch model, language, device, fast or typing live", args: liveSettings, run: Set},
+		{names: []string{"reload"}, summary: "Apply config.toml changes to the running instance", run: noArgs(Reload)},
+		{names: []string{"status"}, usage: "[--json]", summary: "Show what the running instance is doing", flags: statusFlagSpecs, run: Status},
+		{names: []string{"attach"}, summary: "Follow a running instance's status and transcripts", run: noArgs(Attach)},
+		{names: []string{"logs"}, usage: "[-f]", summary: "Show (or follow) the daemon log", flags: logsFlagSpecs, run: Logs},
+		{
+			names: []string{"service"}, usage: "<action>", summary: "Run on login with systemd", run: Service,
+			sub: []command{
+				{names: []string{"install"}, usage: "[options]", summary: "Write, enable and start the unit", flags: serviceFlagSpecs, run: installService},
+				{names: []string{"uninstall", "remove"}, summary: "Stop, disable and remove the unit", run: noArgs(uninstallService)},
+				{names: []string{"status"}, summary: "Show whether the unit is enabled and active", run: noArgs(serviceStatus)},
+			},
+		},
+		{names: []string{"output", "log", "cat", "show"}, summary: "View output file contents", run: noArgs(Output)},
+		{names: []string{"models"}, summary: "Show installed models", run: noArgs(Models)},
+		{
+			names: []string{"config"}, summary: "Open config file in $EDITOR", run: Config,
+			sub: []command{
+				{names: []string{"check"}, summary: "Validate config file (also done by start)", run: noArgs(ConfigCheck)},
+				{names: []string{"migrate"}, usage: "[--dry-run]", summary: "Upgrade an old config file, keeping a backup", flags: migrateFlagSpecs, run: ConfigMigrate},
+				{names: []string{"show"}, usage: "[--effective [options]]", summary: "Print config.toml, or final settings with --effective", flags: showFlagSpecs, run: ConfigShow},
+				{names: []string{"list"}, summary: "Every setting, defaults included", flags: configProfileFlags, run: ConfigList},
+				{names: []string{"get"}, usage: "KEY", summary: "Print one setting", flags: configProfileFlags, args: configKeys, run: ConfigGet},
+				{names: []string{"set"}, usage: "KEY VALUE", summary: "Change a setting, keeping comments", flags: configProfileFlags, args: configKeys, run: ConfigSet},
+				{names: []string{"unset"}, usage: "KEY", summary: "Back to the default", flags: configProfileFlags, args: configKeys, run: ConfigUnset},
+			},
+		},
+		{names: []string{"profiles"}, summary: "List config profiles", run: noArgs(Profiles)},
+		{names: []string{"completion"}, usage: "SHELL", summary: "Print a bash, zsh or fish completion script", args: completionShells, run: Completion},
+		{names: []string{"version", "--version", "-v"}, summary: "Show version and check for updates", run: noArgs(ShowVersion)},
+		{names: []string{"update"}, usage: "[--force]", summary: "Update to latest version", flags: updateFlagSpecs, run: Update},
+		{names: []string{"help", "--help", "-h"}, usage: "[topic]", summary: "Show this help, or more on a topic", args: helpTopicNames, run: Help},
+		{names: []string{completeCommand}, hidden: true, run: complete},
+	}
+}
+
 func Parse(args []string) {
 	if len(args) < 2 {
 		Help([]string{})
 		return
 	}
 
-	command := args[1]
-
-	switch command {
-	case "help", "--help", "-h":
-		Help(args[2:])
-	case "version", "--version", "-v":
-		ShowVersion()
-	case "models":
-		Models()
-	case "config":
-		Config()
-	case "start":
-		Start(args[2:])
-	case "toggle":
-		Toggle(args[2:])
-	case "pause":
-		Pause()
-	case "resume":
-		Resume()
-	case "stop", "kill":
-		Stop()
-	case "output", "log", "cat", "show":
-		Output()
-	case "update":
-		Update(args[2:])
-	default:
-		fmt.Fprintf(os.Stderr, "unknown command: %s\n", command)
+	cmd := lookupCommand(yapCommands(), args[1])
+	if cmd == nil {
+		fmt.Fprintf(os.Stderr, "unknown command: %s\n", args[1])
 		fmt.Fprintln(os.Stderr, "run 'yap help' for usage")
 		os.Exit(1)
 	}
+	runCommand("", cmd, args[2:])
+}
+
+// runCommand checks the flags, then runs cmd (or the subcommand args name).
+func runCommand(parent string, cmd *command, args []string) {
+	path := strings.TrimSpace(parent + " " + cmd.name())
+	if len(args) > 0 && len(cmd.sub) > 0 {
+		if sub := lookupCommand(cmd.sub, args[0]); sub != nil {
+			runCommand(path, sub, args[1:])
+			return
+		}
+	}
+
+	if cmd.hidden {
+		cmd.run(args)
+		return
+	}
+	for _, arg := range args {
+		if arg == "--" {
+			break
+		}
+		if arg == "--help" {
+			printCommandHelp(path, cmd)
+			return
+		}
+	}
+	mustParseFlags(path, cmd.flags, args)
+	cmd.run(args)
+}
+
+func lookupCommand(commands []command, name string) *command {
+	for i := range commands {
+		if slices.Contains(commands[i].names, name) {
+			return &commands[i]
+		}
+	}
+	return nil
+}
+
+// noArgs adapts a command that takes no arguments.
+func noArgs(run func()) func([]string) {
+	return func(args []string) {
+		if len(args) > 0 {
+			fmt.Fprintf(os.Stderr, "unexpected argument: %s\n", args[0])
+			fmt.Fprintln(os.Stderr, "run 'yap help' for usage")
+			os.Exit(1)
+		}
+		run()
+	}
+}
+
+// printCommandHelp is `yap <command> --help`.
+func printCommandHelp(path string, cmd *command) {
+	gohelp.PrintHeader("Usage")
+	fmt.Printf("  yap %s %s\n", path, cmd.usage)
+	fmt.Println()
+	fmt.Printf("  %s\n", cmd.summary)
+
+	if len(cmd.sub) > 0 {
+		gohelp.PrintHeader("Commands")
+		printCommands(path, cmd.sub, false)
+	}
+	if len(cmd.flags) > 0 {
+		gohelp.PrintHeader("Options")
+		printFlags(cmd.flags)
+	}
+}
+
+// printCommands lists commands for help. The main help lists subcommands too,
+// by name only; yap <command> --help has their usage.
+func printCommands(parent string, commands []command, withSubs bool) {
+	for i := range commands {
+		cmd := &commands[i]
+		if cmd.hidden {
+			continue
+		}
+		name := strings.TrimSpace(parent + " " + cmd.name())
+		var aliases []string
+		for _, alias := range cmd.names[1:] {
+			if !strings.HasPrefix(alias, "-") {
+				aliases = append(aliases, alias)
+			}
+		}
+		if len(aliases) > 0 {
+			name += " (" + strings.Join(aliases, ", ") + ")"
+		}
+		gohelp.Item(strings.TrimSpace(name+" "+cmd.usage), cmd.summary)
+		if withSubs {
+			for _, sub := range cmd.sub {
+				gohelp.Item(cmd.name()+" "+sub.name(), sub.summary)
+			}
+		}
+	}
 }
@@ -3,25 +3,18 @@ package commands
 import (
 	"fmt"
 	"os"
-	"syscall"
 
 	"yappers-of-linux/internal"
 )
 
 func Pause() {
-	pid, err := internal.GetPID()
+	resp, err := internal.SendControlTimeout(signalReplyTimeout, "pause")
 	if err != nil {
-		fmt.Println("not running")
-		os.Exit(1)
+		exitControlError(err)
 	}
 
-	if err := syscall.Kill(pid, syscall.SIGUSR1); err != nil {
-		fmt.Fprintf(os.Stderr, "failed to pause: %v\n", err)
+	if !resp.OK {
+		fmt.Fprintf(os.Stderr, "failed to pause: %s\n", resp.Error)
 		os.Exit(1)
 	}
-
-	os.WriteFile(internal.GetStateFile(), []byte("paused"), 0644)
-
-	cfg := internal.LoadConfig()
-	internal.Notify("Yapping paused", "pause", cfg)
 }
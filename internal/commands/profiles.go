@@ -0,0 +1,35 @@
+package commands
+
+import (
+	"fmt"
+
+	"yappers-of-linux/internal"
+)
+
+func Profiles() {
+	cfg := internal.LoadConfig()
+
+	names := cfg.ProfileNames()
+	if len(names) == 0 {
+		fmt.Println("no profiles defined (add [profiles.<name>] tables to config.toml)")
+		return
+	}
+
+	active := ""
+	if resp, err := internal.SendControl("status"); err == nil && resp.Status != nil {
+		active = resp.Status.Profile
+	}
+
+	for _, name := range names {
+		marker := " "
+		if name == active {
+			marker = "*"
+		}
+
+		note := ""
+		if name == cfg.Profile {
+			note = " (default)"
+		}
+		fmt.Printf("%s %s%s\n", marker, name, note)
+	}
+}
@@ -0,0 +1,285 @@
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"yappers-of-linux/internal"
+)
+
+// Loading a model may include downloading it
+const configureTimeout = readyTimeout
+
+// configure sends changed settings to the running engine and waits until it
+// has applied them (for a new model: loaded and warmed up).
+func (s *supervisor) configure(settings map[string]any) error {
+	_, err := s.engineRequest("configure", map[string]any{"settings": settings}, configureTimeout)
+	return err
+}
+
+// engineRequest writes a command to the engine's command pipe and waits for its reply.
+// A reply with ok=false is returned along with its error.
+func (s *supervisor) engineRequest(cmd string, fields map[string]any, timeout time.Duration) (internal.ReplyEvent, error) {
+	reply := make(chan internal.ReplyEvent, 1)
+
+	s.mu.Lock()
+	if s.engineIn == nil || s.state == "initializing" {
+		s.mu.Unlock()
+		return internal.ReplyEvent{}, fmt.Errorf("engine is still initializing")
+	}
+	s.nextID++
+	id := s.nextID
+	s.pending[id] = reply
+	engineIn := s.engineIn
+	s.mu.Unlock()
+
+	request := map[string]any{"id": id, "cmd": cmd}
+	for k, v := range fields {
+		request[k] = v
+	}
+	line, _ := json.Marshal(request)
+	if _, err := engineIn.Write(append(line, '\n')); err != nil {
+		s.dropPending(id)
+		return internal.ReplyEvent{}, fmt.Errorf("failed to reach engine: %w", err)
+	}
+
+	select {
+	case r := <-reply:
+		if !r.OK {
+			return r, fmt.Errorf("%s", r.Error)
+		}
+		return r, nil
+	case <-time.After(timeout):
+		s.dropPending(id)
+		return internal.ReplyEvent{}, fmt.Errorf("engine did not answer %s within %s", cmd, timeout)
+	}
+}
+
+func (s *supervisor) dropPending(id int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.pending, id)
+}
+
+// failPending answers every outstanding configure with an error. Requires s.mu.
+func (s *supervisor) failPending(reason string) {
+	for id, reply := range s.pending {
+		reply <- internal.ReplyEvent{ID: id, Error: reason}
+		delete(s.pending, id)
+	}
+}
+
+func (s *supervisor) deliverReply(reply internal.ReplyEvent) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if ch, ok := s.pending[reply.ID]; ok {
+		ch <- reply
+		delete(s.pending, reply.ID)
+	}
+}
+
+func (s *supervisor) reportLoading(progress internal.LoadingEvent) {
+	s.mu.Lock()
+	s.publish(internal.ControlEvent{Type: "loading", Text: progress.Stage})
+	s.mu.Unlock()
+
+	message := "Model: " + progress.Stage
+	if progress.Progress == nil {
+		s.notify(message, "loading")
+		return
+	}
+	percent := int(*progress.Progress * 100)
+	s.notifier.Progress(fmt.Sprintf("%s (%d%%)", message, percent), "loading", percent, s.config())
+}
+
+// set changes one engine setting at runtime. It is not written to config.toml.
+func (s *supervisor) set(key, value string) internal.ControlResponse {
+	opts := s.options()
+	settings := map[string]any{}
+
+	switch key {
+	case "model":
+		if !isValidModel(value) {
+			return internal.ControlResponse{Error: fmt.Sprintf("unknown model: %s (%s)", value, strings.Join(internal.Models, ", "))}
+		}
+		opts.model = value
+		settings["model"] = value
+	case "language", "lang":
+		opts.language = value
+		settings["language"] = value
+	case "device":
+		if value == "cuda" {
+			value = "gpu"
+		}
+		if value != "cpu" && value != "gpu" {
+			return internal.ControlResponse{Error: "device must be cpu or gpu"}
+		}
+		opts.device = value
+		settings["device"] = value
+	case "fast", "fast_mode":
+		fast, err := strconv.ParseBool(value)
+		if err != nil {
+			return internal.ControlResponse{Error: "fast must be true or false"}
+		}
+		opts.fastMode = fast
+		settings["fast"] = fast
+	case "typing", "enable_typing":
+		typing, err := strconv.ParseBool(value)
+		if err != nil {
+			return internal.ControlResponse{Error: "typing must be true or false"}
+		}
+		opts.enableTyping = typing
+		if err := opts.resolveTyping(); err != nil {
+			return internal.ControlResponse{Error: "cannot enable typing: " + err.Error()}
+		}
+		settings["enable_typing"] = typing
+		settings["typing_backends"] = opts.typingBackends
+	default:
+		return internal.ControlResponse{Error: "unknown setting: " + key + " (model, language, device, fast, typing)"}
+	}
+
+	if err := s.configure(settings); err != nil {
+		return internal.ControlResponse{State: s.currentState(), Error: err.Error()}
+	}
+
+	s.mu.Lock()
+	s.opts = opts
+	s.mu.Unlock()
+
+	return internal.ControlResponse{OK: true, State: s.currentState(), Message: fmt.Sprintf("%s set to %s", key, value)}
+}
+
+// reload re-reads config.toml (with the same start flags and profile) and
+// applies whatever changed without restarting the engine.
+func (s *supervisor) reload() internal.ControlResponse {
+	cfg, err := internal.ReadConfig()
+	if err != nil {
+		// Falling back to defaults would silently undo the user's settings
+		return internal.ControlResponse{Error: "config.toml: " + err.Error()}
+	}
+	opts, err := resolveStartOptions(cfg, s.startArgs)
+	if err != nil {
+		return internal.ControlResponse{Error: err.Error()}
+	}
+
+	oldCfg, oldOpts := s.config(), s.options()
+	settings := map[string]any{}
+	var changes []string
+
+	track := func(name, key string, old, new any) {
+		if old != new {
+			changes = append(changes, fmt.Sprintf("%s: %v -> %v", name, old, new))
+			if key != "" {
+				settings[key] = new
+			}
+		}
+	}
+
+	// Checked again: a tool may have been installed or its daemon stopped
+	if err := opts.resolveTyping(); err != nil {
+		return internal.ControlResponse{Error: "cannot type: " + err.Error()}
+	}
+
+	track("model", "model", oldOpts.model, opts.model)
+	track("device", "device", oldOpts.device, opts.device)
+	track("fast_mode", "fast", oldOpts.fastMode, opts.fastMode)
+	track("language", "language", oldOpts.language, opts.language)
+	track("enable_typing", "enable_typing", oldOpts.enableTyping, opts.enableTyping)
+	if !slices.Equal(oldOpts.typingBackends, opts.typingBackends) {
+		track("typing backends", "", strings.Join(oldOpts.typingBackends, ","), strings.Join(opts.typingBackends, ","))
+		settings["typing_backends"] = opts.typingBackends
+	}
+	track("output_file", "output_file", oldCfg.OutputFile, cfg.OutputFile)
+	track("flush_on_stop", "flush_on_stop", oldCfg.FlushOnStop, cfg.FlushOnStop)
+	track("capture_mode", "capture_mode", oldCfg.CaptureMode, cfg.CaptureMode)
+	track("audio.silence_duration", "silence_duration", oldCfg.Audio.SilenceDuration, cfg.Audio.SilenceDuration)
+	track("audio.pre_buffer", "pre_buffer", oldCfg.Audio.PreBuffer, cfg.Audio.PreBuffer)
+	track("vad.aggressiveness", "vad_aggressiveness", oldCfg.VAD.Aggressiveness, cfg.VAD.Aggressiveness)
+	track("transcription.beam_size", "beam_size", oldCfg.Transcription.BeamSize, cfg.Transcription.BeamSize)
+	track("transcription.min_confidence", "min_confidence", oldCfg.Transcription.MinConfidence, cfg.Transcription.MinConfidence)
+	track("transcription.min_audio_duration", "min_audio_duration", oldCfg.Transcription.MinAudioDuration, cfg.Transcription.MinAudioDuration)
+	if !reflect.DeepEqual(oldCfg.Apps, cfg.Apps) {
+		changes = append(changes, fmt.Sprintf("app rules: %d -> %d", len(oldCfg.Apps), len(cfg.Apps)))
+		settings["app_rules"] = cfg.Apps
+	}
+	track("notifications", "", oldCfg.Notifications, cfg.Notifications)
+	if oldCfg.Sounds != cfg.Sounds {
+		changes = append(changes, "sounds")
+	}
+	track("stop_timeout", "", oldCfg.StopTimeout, cfg.StopTimeout)
+	track("timeout", "", oldCfg.Timeout, cfg.Timeout)
+	track("idle_stop", "", oldCfg.IdleStop, cfg.IdleStop)
+
+	if opts.tcpPort != oldOpts.tcpPort {
+		changes = append(changes, "tcp_port: takes effect after a restart")
+	}
+
+	if len(settings) > 0 {
+		if err := s.configure(settings); err != nil {
+			return internal.ControlResponse{State: s.currentState(), Error: err.Error()}
+		}
+	}
+
+	s.mu.Lock()
+	s.cfg = cfg
+	s.opts = opts
+	s.mu.Unlock()
+
+	message := "no changes"
+	if len(changes) > 0 {
+		message = strings.Join(changes, ", ")
+	}
+	return internal.ControlResponse{OK: true, State: s.currentState(), Message: message}
+}
+
+func isValidModel(model string) bool {
+	return internal.IsOneOf(model, internal.Models)
+}
+
+// Set implements `yap set <setting> <value>`.
+func Set(args []string) {
+	if len(args) != 2 {
+		fmt.Fprintln(os.Stderr, "usage: yap set <model|language|device|fast|typing> <value>")
+		os.Exit(1)
+	}
+	runWithProgress("set", args...)
+}
+
+// Reload implements `yap reload`.
+func Reload() {
+	runWithProgress("reload")
+}
+
+// runWithProgress sends a request that may load a model, printing loading
+// progress from the instance until the response arrives.
+func runWithProgress(cmd string, args ...string) {
+	events, err := internal.Subscribe()
+	if err != nil {
+		exitControlError(err)
+	}
+
+	go func() {
+		for event := range events {
+			if event.Type == "loading" {
+				fmt.Printf("%s...\n", event.Text)
+			}
+		}
+	}()
+
+	resp, err := internal.SendControlTimeout(configureTimeout, cmd, args...)
+	if err != nil {
+		exitControlError(err)
+	}
+
+	if !resp.OK {
+		fmt.Fprintf(os.Stderr, "%s failed: %s\n", cmd, resp.Error)
+		os.Exit(1)
+	}
+	fmt.Println(resp.Message)
+}
@@ -3,25 +3,18 @@ package commands
 import (
 	"fmt"
 	"os"
-	"syscall"
 
 	"yappers-of-linux/internal"
 )
 
 func Resume() {
-	pid, err := internal.GetPID()
+	resp, err := internal.SendControlTimeout(signalReplyTimeout, "resume")
 	if err != nil {
-		fmt.Println("not running")
-		os.Exit(1)
+		exitControlError(err)
 	}
 
-	if err := syscall.Kill(pid, syscall.SIGUSR2); err != nil {
-		fmt.Fprintf(os.Stderr, "failed to resume: %v\n", err)
+	if !resp.OK {
+		fmt.Fprintf(os.Stderr, "failed to resume: %s\n", resp.Error)
 		os.Exit(1)
 	}
-
-	os.WriteFile(internal.GetStateFile(), []byte("active"), 0644)
-
-	cfg := internal.LoadConfig()
-	internal.Notify("Yapping started", "start", cfg)
 }
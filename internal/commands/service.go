@@ -0,0 +1,161 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"yappers-of-linux/internal"
+)
+
+// serviceFlagSpecs are the options of service install; systemd already runs
+// yap in the background, so --daemon is not one of them.
+var serviceFlagSpecs = withoutFlags(startFlagSpecs, "--daemon")
+
+// Service is `yap service` without a known action (see yapCommands).
+func Service(args []string) {
+	if len(args) > 0 {
+		fmt.Fprintf(os.Stderr, "unknown service command: %s\n", args[0])
+	}
+	fmt.Fprintln(os.Stderr, "usage: yap service install [start options] | uninstall | status")
+	os.Exit(1)
+}
+
+func requireSystemctl() {
+	if !internal.HasCommand("systemctl") {
+		fmt.Fprintln(os.Stderr, "systemctl not found (systemd is required for yap service)")
+		os.Exit(1)
+	}
+}
+
+func installService(startArgs []string) {
+	cfg := internal.LoadConfig()
+	if _, err := resolveStartOptions(cfg, startArgs); err != nil {
+		fmt.Fprintf(os.Stderr, "yap service install: %v\n", err)
+		os.Exit(1)
+	}
+	requireSystemctl()
+
+	exe, err := os.Executable()
+	if err == nil {
+		exe, err = filepath.EvalSymlinks(exe)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to locate yap binary: %v\n", err)
+		os.Exit(1)
+	}
+
+	unitPath, err := internal.GetServiceFile()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to get unit path: %v\n", err)
+		os.Exit(1)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(unitPath), 0755); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to create %s: %v\n", filepath.Dir(unitPath), err)
+		os.Exit(1)
+	}
+
+	if err := os.WriteFile(unitPath, []byte(internal.ServiceUnit(exe, startArgs, time.Duration(cfg.StopTimeout)*time.Second+stopGrace)), 0644); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to write unit file: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("wrote %s\n", unitPath)
+
+	if err := systemctl("daemon-reload"); err != nil {
+		os.Exit(1)
+	}
+
+	// reenable drops the links of an older unit's [Install] section
+	if err := systemctl("reenable", internal.ServiceName); err != nil {
+		os.Exit(1)
+	}
+
+	// A manually started instance holds the socket, so the service would exit right away
+	if resp, err := internal.SendControl("status"); err == nil && resp.Status != nil && resp.Status.Manager != "systemd" {
+		fmt.Println("enabled; an instance is already running outside systemd")
+		fmt.Println("run 'yap stop && systemctl --user start yap' to hand it over")
+		return
+	}
+
+	if err := systemctl("start", internal.ServiceName); err != nil {
+		os.Exit(1)
+	}
+	fmt.Println("enabled and started (starts with your graphical session)")
+}
+
+func uninstallService() {
+	requireSystemctl()
+	unitPath, err := internal.GetServiceFile()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to get unit path: %v\n", err)
+		os.Exit(1)
+	}
+
+	if _, err := os.Stat(unitPath); os.IsNotExist(err) {
+		fmt.Println("service not installed")
+		return
+	}
+
+	if err := systemctl("disable", "--now", internal.ServiceName); err != nil {
+		os.Exit(1)
+	}
+
+	if err := os.Remove(unitPath); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to remove unit file: %v\n", err)
+		os.Exit(1)
+	}
+
+	systemctl("daemon-reload")
+	fmt.Println("service removed")
+}
+
+func serviceStatus() {
+	requireSystemctl()
+	unitPath, err := internal.GetServiceFile()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to get unit path: %v\n", err)
+		os.Exit(1)
+	}
+
+	if _, err := os.Stat(unitPath); os.IsNotExist(err) {
+		fmt.Println("not installed (run 'yap service install')")
+		os.Exit(statusStopped)
+	}
+
+	enabled := systemctlQuery("is-enabled", internal.ServiceName)
+	active := systemctlQuery("is-active", internal.ServiceName)
+
+	fmt.Printf("installed: %s\n", unitPath)
+	printStatusField("enabled", enabled)
+	printStatusField("active", active)
+	if active != "active" {
+		fmt.Println("\nsee 'journalctl --user -u yap' for details")
+		os.Exit(statusStopped)
+	}
+}
+
+func systemctl(args ...string) error {
+	cmd := exec.Command("systemctl", append([]string{"--user"}, args...)...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	if err := cmd.Run(); err != nil {
+		fmt.Fprintf(os.Stderr, "systemctl --user %s failed: %v\n", strings.Join(args, " "), err)
+		return err
+	}
+	return nil
+}
+
+// systemctlQuery returns the one-word answer of is-enabled/is-active (they exit non-zero for "no").
+func systemctlQuery(args ...string) string {
+	out, _ := exec.Command("systemctl", append([]string{"--user"}, args...)...).Output()
+	answer := strings.TrimSpace(string(out))
+	if answer == "" {
+		return "unknown"
+	}
+	return answer
+}
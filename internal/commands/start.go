@@ -1,7 +1,7 @@
 package commands
 
 import (
-	"bufio"
+	"encoding/json"
 	"fmt"
 	"os"
 	"os/exec"
@@ -10,14 +10,16 @@ import (
 	"strconv"
 	"strings"
 	"syscall"
+	"time"
 
 	"yappers-of-linux/internal"
 )
 
 func Start(args []string) {
-	pid, err := internal.GetPID()
-	if err == nil {
-		fmt.Printf("already running (pid %d)\n", pid)
+	internal.ContinueDaemon()
+
+	if internal.InstanceRunning() {
+		printAlreadyRunning()
 		return
 	}
 
@@ -26,8 +28,50 @@ func Start(args []string) {
 		os.Exit(1)
 	}
 
+	if !preflightConfig() {
+		os.Exit(1)
+	}
+
 	cfg := internal.LoadConfig()
 
+	opts, err := resolveStartOptions(cfg, args)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	systemDir, err := internal.GetSystemDir()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to get system directory: %v\n", err)
+		os.Exit(1)
+	}
+
+	venvPython := filepath.Join(systemDir, "venv", "bin", "python")
+	script := filepath.Join(systemDir, "main.py")
+
+	if err := opts.resolveTyping(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, "install one, choose another with typing_backend, or start without typing: yap start --no-typing")
+		os.Exit(1)
+	}
+
+	if opts.daemon {
+		startDaemon(args)
+		return
+	}
+
+	// The check above is only a fast path; two starts can race up to here
+	lock, err := internal.AcquireInstanceLock()
+	if err == internal.ErrAlreadyRunning {
+		printAlreadyRunning()
+		return
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	defer lock.Release()
+
 	// Clean up previous output file (ephemeral, always fresh)
 	configDir, err := internal.GetConfigDir()
 	if err == nil {
@@ -35,89 +79,196 @@ func Start(args []string) {
 		os.Remove(outputFile) // Ignore error if doesn't exist
 	}
 
-	systemDir, err := internal.GetSystemDir()
+	sup := newSupervisor(cfg, opts, args, venvPython, script, engineEnv(systemDir))
+
+	exited, err := sup.spawn()
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "failed to get system directory: %v\n", err)
+		fmt.Fprintf(os.Stderr, "failed to start: %v\n", err)
 		os.Exit(1)
 	}
 
-	venvPython := filepath.Join(systemDir, "venv", "bin", "python")
-	script := filepath.Join(systemDir, "main.py")
+	server, err := internal.ListenControl(sup.handle, sup.subscribe)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "warning: control socket unavailable: %v\n", err)
+	} else {
+		defer server.Close()
+	}
 
-	model := cfg.Model
-	device := cfg.Device
-	language := cfg.Language
-	fastMode := cfg.FastMode
-	enableTyping := cfg.EnableTyping
-	tcpPort := ""
-	if cfg.TCPPort > 0 {
-		tcpPort = strconv.Itoa(cfg.TCPPort)
-	}
-
-	for i := 0; i < len(args); i++ {
-		arg := args[i]
-		if arg == "--model" && i+1 < len(args) {
-			model = args[i+1]
-		} else if arg == "--device" && i+1 < len(args) {
-			device = args[i+1]
-		} else if arg == "--language" && i+1 < len(args) {
-			language = args[i+1]
-		} else if arg == "--lang" && i+1 < len(args) {
-			language = args[i+1]
-		} else if arg == "--tcp" {
-			if i+1 < len(args) && !strings.HasPrefix(args[i+1], "--") {
-				tcpPort = args[i+1]
-				i++
-			} else {
-				tcpPort = "12322"
+	// Setup signal handler for graceful shutdown
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM, syscall.SIGHUP)
+	go func() {
+		for sig := range sigChan {
+			// SIGHUP reloads config when detached; in a terminal it means the terminal went away
+			if sig == syscall.SIGHUP && (internal.IsDaemon() || internal.IsSystemdService()) {
+				resp := sup.handle(internal.ControlRequest{Cmd: "reload"})
+				fmt.Fprintf(os.Stderr, "reload: %s%s\n", resp.Message, resp.Error)
+				continue
 			}
-		} else if arg == "--fast" {
-			fastMode = true
-		} else if arg == "--no-typing" {
-			enableTyping = false
-		} else if arg == "--gpu" || arg == "--cuda" {
-			device = "cuda"
-		} else if arg == "--cpu" {
-			device = "cpu"
+			sup.requestShutdown()
 		}
+	}()
+
+	sup.run(exited)
+
+	sup.closeSubscribers()
+	sup.notifier.Close()
+}
+
+func printAlreadyRunning() {
+	if pid, err := internal.GetPID(); err == nil {
+		fmt.Printf("already running (pid %d)\n", pid)
+	} else {
+		fmt.Println("already running")
 	}
+}
 
-	if enableTyping {
-		if err := internal.CheckTypingDependencies(); err != nil {
-			os.Exit(1)
+// startOptions are the engine settings after config, profile and flags are combined.
+type startOptions struct {
+	profile      string
+	model        string
+	device       string
+	language     string
+	fastMode     bool
+	enableTyping bool
+	tcpPort      string
+	daemon       bool
+
+	typingBackend  string   // the setting
+	typingBackends []string // what the engine tries, see resolveTyping
+}
+
+// resolveStartOptions resolves cfg (see Config.Resolve) with the flags as the
+// top layer and returns the engine settings.
+func resolveStartOptions(cfg *internal.Config, args []string) (startOptions, error) {
+	overrides, daemon, err := startFlags(args)
+	if err != nil {
+		return startOptions{}, err
+	}
+	if err := cfg.Resolve(overrides); err != nil {
+		return startOptions{}, err
+	}
+	if err := cfg.Validate(); err != nil {
+		return startOptions{}, err
+	}
+
+	opts := startOptions{
+		profile:      cfg.Profile,
+		model:        cfg.Model,
+		device:       cfg.Device,
+		language:     cfg.Language,
+		fastMode:     cfg.FastMode,
+		enableTyping: cfg.EnableTyping,
+		daemon:       daemon,
+
+		typingBackend: cfg.TypingBackend,
+	}
+	if opts.device == "cuda" {
+		// Another name for gpu, from before config_version 1
+		opts.device = "gpu"
+	}
+	if cfg.TCPPort > 0 {
+		opts.tcpPort = strconv.Itoa(cfg.TCPPort)
+	}
+
+	return opts, nil
+}
+
+// resolveTyping finds the typing backends the engine can use. Having none is
+// an error only while typing is enabled; the engine gets them either way, for
+// when typing is switched on.
+func (o *startOptions) resolveTyping() error {
+	chain, err := internal.TypingChain(o.typingBackend)
+	o.typingBackends = chain
+	if o.enableTyping {
+		return err
+	}
+	return nil
+}
+
+// startFlagSpecs are the options of start and toggle; once, transcribe,
+// service install and config show --effective take them as well.
+var startFlagSpecs = []flagSpec{
+	{names: []string{"--model"}, arg: "X", setting: "model", values: completeModels, help: "Model size: tiny, base, small, medium, large"},
+	{names: []string{"--device"}, arg: "X", setting: "device", values: devices, help: "cpu or gpu"},
+	{names: []string{"--cpu"}, setting: "device", value: "cpu", help: "Same as --device cpu"},
+	{names: []string{"--gpu", "--cuda"}, setting: "device", value: "gpu", help: "Same as --device gpu"},
+	{names: []string{"--language", "--lang"}, arg: "X", setting: "language", help: "Language code, auto to detect (default: en)"},
+	{names: []string{"--tcp"}, arg: "PORT", optional: true, def: "12322", setting: "tcp_port", help: "Enable TCP server (default port: 12322)"},
+	{names: []string{"--fast"}, setting: "fast_mode", value: "true", help: "Use fast mode (int8, less accurate but faster)"},
+	{names: []string{"--no-typing"}, setting: "enable_typing", value: "false", help: "Disable keyboard typing (only print to terminal)"},
+	{names: []string{"--typing-backend"}, arg: "X", setting: "typing_backend", values: typingBackendValues, help: "Typing tool, or several to try in order (default: auto)"},
+	{names: []string{"--output-file"}, setting: "output_file", value: "true", help: "Write transcriptions to output.txt"},
+	{names: []string{"--timeout"}, arg: "N", setting: "timeout", help: "Auto-pause after N seconds without output (0 = never)"},
+	{names: []string{"--notifications"}, arg: "LIST", setting: "notifications", values: notificationValues, help: `Notify on e.g. "start,stop" ("false" = never)`},
+	{names: []string{"--profile"}, arg: "NAME", setting: "profile", values: completeProfiles, help: "Apply a [profiles.NAME] table from config"},
+	{names: []string{"--daemon", "-d"}, help: "Run in the background (see yap attach / yap logs)"},
+}
+
+// startFlags turns start flags into config overrides. --daemon is the only
+// one that is not a setting.
+func startFlags(args []string) (overrides []internal.Override, daemon bool, err error) {
+	p, err := parseFlags(startFlagSpecs, args)
+	if err != nil {
+		return nil, false, err
+	}
+	if len(p.args) > 0 {
+		return nil, false, fmt.Errorf("unexpected argument: %s", p.args[0])
+	}
+	for _, f := range p.flags {
+		if f.spec.setting != "" {
+			overrides = append(overrides, internal.Override{Key: f.spec.setting, Value: f.value, Source: f.name})
 		}
 	}
+	return overrides, p.has("--daemon"), nil
+}
 
-	pythonArgs := []string{script, "--model", model, "--device", device, "--language", language}
-	if fastMode {
+// engineArgs builds the main.py command line for the given settings.
+func engineArgs(script string, cfg *internal.Config, opts startOptions) []string {
+	pythonArgs := []string{script, "--model", opts.model, "--device", opts.device, "--language", opts.language, "--capture-mode", cfg.CaptureMode}
+	if opts.fastMode {
 		pythonArgs = append(pythonArgs, "--fast")
 	}
-	if !enableTyping {
+	if !opts.enableTyping {
 		pythonArgs = append(pythonArgs, "--no-typing")
 	}
+	if len(opts.typingBackends) > 0 {
+		pythonArgs = append(pythonArgs, "--typing-backends", strings.Join(opts.typingBackends, ","))
+	}
 	if cfg.OutputFile {
 		pythonArgs = append(pythonArgs, "--output-file")
 	}
-	if tcpPort != "" {
-		pythonArgs = append(pythonArgs, "--tcp", tcpPort)
+	if opts.tcpPort != "" {
+		pythonArgs = append(pythonArgs, "--tcp", opts.tcpPort)
 	}
-	if cfg.Timeout > 0 {
-		pythonArgs = append(pythonArgs, "--timeout", strconv.Itoa(cfg.Timeout))
+	if cfg.FlushOnStop {
+		pythonArgs = append(pythonArgs, "--flush-on-stop")
 	}
+	pythonArgs = append(pythonArgs,
+		"--silence-duration", fmt.Sprint(cfg.Audio.SilenceDuration),
+		"--pre-buffer", fmt.Sprint(cfg.Audio.PreBuffer),
+		"--vad-aggressiveness", strconv.Itoa(cfg.VAD.Aggressiveness),
+	)
+	if len(cfg.Apps) > 0 {
+		rules, _ := json.Marshal(cfg.Apps)
+		pythonArgs = append(pythonArgs, "--app-rules", string(rules))
+	}
+	return append(pythonArgs, transcriptionArgs(cfg)...)
+}
 
-	cmd := exec.Command(venvPython, pythonArgs...)
-	cmd.Stdout = os.Stdout
-	cmd.Stdin = os.Stdin
-
-	// Capture stderr to watch for state markers
-	stderr, err := cmd.StderrPipe()
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "failed to create stderr pipe: %v\n", err)
-		os.Exit(1)
+// transcriptionArgs passes the [transcription] settings, shared with file mode.
+func transcriptionArgs(cfg *internal.Config) []string {
+	return []string{
+		"--beam-size", strconv.Itoa(cfg.Transcription.BeamSize),
+		"--min-confidence", fmt.Sprint(cfg.Transcription.MinConfidence),
+		"--min-audio-duration", fmt.Sprint(cfg.Transcription.MinAudioDuration),
 	}
+}
 
+// engineEnv is the environment for the engine process.
+func engineEnv(systemDir string) []string {
 	// Set LD_LIBRARY_PATH for CUDA libraries (cuBLAS, cuDNN)
-	cmd.Env = os.Environ()
+	env := os.Environ()
 	sitePackages := filepath.Join(systemDir, "venv", "lib", "python3.10", "site-packages")
 	cudaLibPaths := filepath.Join(sitePackages, "nvidia", "cublas", "lib") + ":" +
 		filepath.Join(sitePackages, "nvidia", "cudnn", "lib")
@@ -125,52 +276,46 @@ func Start(args []string) {
 	if currentLdPath != "" {
 		cudaLibPaths = cudaLibPaths + ":" + currentLdPath
 	}
-	cmd.Env = append(cmd.Env, "LD_LIBRARY_PATH="+cudaLibPaths)
+	return append(env, "LD_LIBRARY_PATH="+cudaLibPaths)
+}
 
-	if err := cmd.Start(); err != nil {
-		fmt.Fprintf(os.Stderr, "failed to start: %v\n", err)
-		os.Exit(1)
+// eventPipe hands the engine the write end of a pipe as its next extra fd, for
+// its JSON-lines event stream, and returns both ends. Close w once the engine
+// has started; r then reaches EOF when the engine exits.
+func eventPipe(cmd *exec.Cmd) (r, w *os.File, err error) {
+	r, w, err = os.Pipe()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create event pipe: %w", err)
 	}
+	cmd.ExtraFiles = append(cmd.ExtraFiles, w)
+	cmd.Args = append(cmd.Args, "--event-fd", strconv.Itoa(2+len(cmd.ExtraFiles)))
+	return r, w, nil
+}
 
-	pidData := []byte(strconv.Itoa(cmd.Process.Pid))
-	if err := os.WriteFile(internal.GetPIDFile(), pidData, 0644); err != nil {
-		fmt.Fprintf(os.Stderr, "warning: failed to write pid file: %v\n", err)
+// startDaemon relaunches `yap start` detached and waits until its control socket answers.
+func startDaemon(args []string) {
+	startArgs := []string{"start"}
+	for _, arg := range args {
+		if arg != "--daemon" && arg != "-d" {
+			startArgs = append(startArgs, arg)
+		}
 	}
 
-	// Setup signal handler for graceful shutdown
-	sigChan := make(chan os.Signal, 1)
-	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
-	go func() {
-		<-sigChan
-		// Send notification before cleanup
-		internal.Notify("Yapping stopped", "stop", cfg)
-		// Kill the Python process
-		if cmd.Process != nil {
-			cmd.Process.Kill()
-		}
-		// Cleanup
-		os.Remove(internal.GetPIDFile())
-		os.Remove(internal.GetStateFile())
-		os.Exit(0)
-	}()
+	if err := internal.Daemonize(startArgs); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to start daemon: %v\n", err)
+		os.Exit(1)
+	}
 
-	// Read stderr line by line, watching for state markers
-	go func() {
-		scanner := bufio.NewScanner(stderr)
-		for scanner.Scan() {
-			line := scanner.Text()
-
-			// Watch for state markers
-			if strings.Contains(line, "SYSTEM_READY") {
-				internal.Notify("Yapping started", "start", cfg)
-			} else {
-				// Print other stderr output
-				fmt.Fprintln(os.Stderr, line)
-			}
+	logPath, _ := internal.GetLogFile()
+	for i := 0; i < 50; i++ {
+		if resp, err := internal.SendControl("status"); err == nil && resp.Status != nil {
+			fmt.Printf("started in background (pid %d)\n", resp.Status.PID)
+			fmt.Printf("logs: %s\n", logPath)
+			return
 		}
-	}()
+		time.Sleep(100 * time.Millisecond)
+	}
 
-	cmd.Wait()
-	os.Remove(internal.GetPIDFile())
-	os.Remove(internal.GetStateFile())
+	fmt.Fprintf(os.Stderr, "daemon did not come up, check %s\n", logPath)
+	os.Exit(1)
 }
@@ -0,0 +1,102 @@
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"yappers-of-linux/internal"
+)
+
+// Exit codes for `yap status`, so scripts can branch without parsing output
+const (
+	statusActive       = 0
+	statusError        = 1
+	statusPaused       = 2
+	statusStopped      = 3
+	statusInitializing = 4
+)
+
+var statusFlagSpecs = []flagSpec{
+	{names: []string{"--json"}, help: "Print the status as JSON"},
+}
+
+func Status(args []string) {
+	asJSON := mustParseFlags("status", statusFlagSpecs, args).has("--json")
+
+	info := &internal.StatusInfo{Running: false, State: "stopped"}
+
+	resp, err := internal.SendControl("status")
+	if err != nil && err != internal.ErrNotRunning {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(statusError)
+	}
+	if err == nil {
+		if !resp.OK || resp.Status == nil {
+			fmt.Fprintf(os.Stderr, "failed to get status: %s\n", resp.Error)
+			os.Exit(statusError)
+		}
+		info = resp.Status
+	}
+
+	if asJSON {
+		data, _ := json.MarshalIndent(info, "", "  ")
+		fmt.Println(string(data))
+	} else {
+		printStatus(info)
+	}
+
+	os.Exit(statusExitCode(info))
+}
+
+func printStatus(info *internal.StatusInfo) {
+	if !info.Running {
+		fmt.Println("not running")
+		return
+	}
+
+	fmt.Printf("running (pid %d)\n", info.PID)
+	printStatusField("state", info.State)
+	printStatusField("model", info.Model)
+	printStatusField("device", info.Device)
+	printStatusField("language", info.Language)
+	printStatusField("capture", info.CaptureMode)
+	if info.Profile != "" {
+		printStatusField("profile", info.Profile)
+	}
+	printStatusField("engine pid", fmt.Sprintf("%d", info.EnginePID))
+	printStatusField("managed by", info.Manager)
+	printStatusField("uptime", (time.Duration(info.UptimeSeconds) * time.Second).String())
+	printStatusField("transcriptions", fmt.Sprintf("%d", info.Transcriptions))
+	if info.Restarts > 0 {
+		printStatusField("restarts", fmt.Sprintf("%d", info.Restarts))
+	}
+	if info.Audio != nil {
+		printStatusField("audio", fmt.Sprintf("%gs silence ends an utterance, %gs pre-buffer", info.Audio.SilenceDuration, info.Audio.PreBuffer))
+	}
+	if info.VAD != nil {
+		printStatusField("vad", fmt.Sprintf("aggressiveness %d", info.VAD.Aggressiveness))
+	}
+	if info.Transcription != nil {
+		t := info.Transcription
+		printStatusField("transcription", fmt.Sprintf("beam %d, min confidence %g, min audio %gs", t.BeamSize, t.MinConfidence, t.MinAudioDuration))
+	}
+}
+
+func printStatusField(name, value string) {
+	fmt.Printf("  %-16s%s\n", name, value)
+}
+
+func statusExitCode(info *internal.StatusInfo) int {
+	switch {
+	case !info.Running:
+		return statusStopped
+	case info.State == "paused":
+		return statusPaused
+	case info.State == "initializing":
+		return statusInitializing
+	default:
+		return statusActive
+	}
+}
@@ -3,26 +3,54 @@ package commands
 import (
 	"fmt"
 	"os"
-	"syscall"
+	"time"
 
 	"yappers-of-linux/internal"
 )
 
+// stopGrace covers the supervisor's own cleanup on top of stop_timeout
+const stopGrace = 5 * time.Second
+
 func Stop() {
-	pid, err := internal.GetPID()
+	// The instance's own stop_timeout, which a profile, YAP_STOP_TIMEOUT or a
+	// flag may have changed from config.toml's
+	stopTimeout := internal.LoadConfig().StopTimeout
+	if resp, err := internal.SendControl("status"); err == nil && resp.Status != nil {
+		stopTimeout = resp.Status.StopTimeout
+	}
+
+	timeout := time.Duration(stopTimeout)*time.Second + stopGrace
+	resp, err := internal.SendControlTimeout(timeout, "stop")
 	if err != nil {
-		fmt.Println("not running")
-		os.Exit(1)
+		exitControlError(err)
 	}
 
-	if err := syscall.Kill(pid, syscall.SIGTERM); err != nil {
-		fmt.Fprintf(os.Stderr, "failed to stop: %v\n", err)
+	if !resp.OK {
+		fmt.Fprintf(os.Stderr, "failed to stop: %s\n", resp.Error)
 		os.Exit(1)
 	}
 
-	os.Remove(internal.GetPIDFile())
-	os.Remove(internal.GetStateFile())
+	// The reply comes once the engine is gone; wait for the supervisor too
+	internal.WaitForExit(stopGrace)
+
+	switch resp.State {
+	case "stopped":
+		fmt.Println("stopped")
+	case "killed":
+		fmt.Printf("stopped (engine killed after %ds, in-flight text may be lost)\n", stopTimeout)
+		os.Exit(1)
+	default:
+		fmt.Println(resp.State)
+		os.Exit(1)
+	}
+}
 
-	cfg := internal.LoadConfig()
-	internal.Notify("Yapping stopped", "stop", cfg)
+// exitControlError reports a failed control request and exits.
+func exitControlError(err error) {
+	if err == internal.ErrNotRunning {
+		fmt.Println("not running")
+	} else {
+		fmt.Fprintln(os.Stderr, err)
+	}
+	os.Exit(1)
 }
@@ -0,0 +1,392 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"yappers-of-linux/internal"
+)
+
+const ackTimeout = 10 * time.Second
+
+// signalReplyTimeout is how long pause, resume and toggle wait for a reply: a
+// signal that lands mid-transcription is acted on once it is done.
+const signalReplyTimeout = processingTimeout + 2*ackTimeout
+
+// supervisor owns the engine process and answers control socket requests.
+// The engine's reported state is the only source of truth for what it is doing.
+type supervisor struct {
+	startArgs []string // `yap start` flags, re-applied on reload
+	python    string
+	script    string
+	env       []string
+
+	mu             sync.Mutex
+	cfg            *internal.Config // replaced on reload
+	opts           startOptions     // replaced on reload and yap set
+	cmd            *exec.Cmd        // current engine process, replaced on restart
+	engineIn       *os.File         // command pipe into the current engine
+	nextID         int
+	pending        map[int]chan internal.ReplyEvent
+	state          string
+	stateSince     time.Time
+	lastBeat       time.Time     // last sign of life: heartbeat or state change
+	lastOutput     time.Time     // idle clock for auto-pause: last transcription, resume or start
+	idlePausing    bool          // an auto-pause is in flight
+	changed        chan struct{} // closed and replaced on every state change
+	model          string
+	device         string
+	language       string
+	startedAt      time.Time
+	transcriptions int
+	restarts       int
+	fatal          string // last fatal error reported by the current engine
+	subscribers    map[chan internal.ControlEvent]struct{}
+	notifier       *internal.Notifier
+
+	ctlMu      sync.Mutex // serializes control requests
+	shutdown   chan struct{}
+	stopOnce   sync.Once
+	done       chan struct{} // closed when run returns and the engine is gone
+	stopResult string        // "stopped" or "killed", set before done is closed
+}
+
+func newSupervisor(cfg *internal.Config, opts startOptions, startArgs []string, python, script string, env []string) *supervisor {
+	s := &supervisor{
+		startArgs:   startArgs,
+		python:      python,
+		script:      script,
+		env:         env,
+		cfg:         cfg,
+		opts:        opts,
+		pending:     make(map[int]chan internal.ReplyEvent),
+		state:       "initializing",
+		changed:     make(chan struct{}),
+		model:       opts.model,
+		device:      opts.device,
+		language:    opts.language,
+		startedAt:   time.Now(),
+		subscribers: make(map[chan internal.ControlEvent]struct{}),
+		shutdown:    make(chan struct{}),
+		done:        make(chan struct{}),
+		stopResult:  "stopped",
+	}
+	// Notification buttons (Pause, Resume, Stop) are control commands
+	s.notifier = internal.NewNotifier(func(action string) {
+		s.handle(internal.ControlRequest{Cmd: action})
+	})
+	return s
+}
+
+func (s *supervisor) config() *internal.Config {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.cfg
+}
+
+func (s *supervisor) options() startOptions {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.opts
+}
+
+func (s *supervisor) notify(message, event string) {
+	s.notifier.Notify(message, event, s.config())
+}
+
+// cue plays the [sounds] cue for event, if it is switched on.
+func (s *supervisor) cue(event string) {
+	internal.PlaySound(event, s.config().Sounds)
+}
+
+func (s *supervisor) currentState() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.state
+}
+
+// setState records a state reported by the engine and fires pause/resume
+// notifications and cues.
+func (s *supervisor) setState(state string) {
+	s.mu.Lock()
+	prev := s.state
+	s.state = state
+	s.stateSince = time.Now()
+	s.lastBeat = s.stateSince
+	if (prev == "paused" || prev == "initializing") && state != prev {
+		s.markActive()
+	}
+	close(s.changed)
+	s.changed = make(chan struct{})
+	s.publish(internal.ControlEvent{Type: "state", State: state})
+	timedOut := s.idlePausing
+	timeout := s.cfg.Timeout
+	s.mu.Unlock()
+
+	if state == "paused" && prev != "paused" {
+		if timedOut && internal.ParseNotifications(s.config().Notifications).ShouldNotify("timeout") {
+			s.notify(fmt.Sprintf("Yapping paused after %ds without output", timeout), "timeout")
+		} else {
+			s.notify("Yapping paused", "pause")
+		}
+		s.cue("pause")
+	} else if prev == "paused" && state != "paused" && state != "initializing" {
+		s.notify("Yapping started", "start")
+		s.cue("ready")
+	} else if state == "processing" && prev != "processing" {
+		s.cue("captured")
+	}
+}
+
+func (s *supervisor) heartbeat() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lastBeat = time.Now()
+}
+
+// setEngineInfo records the settings the engine actually loaded with (e.g. after a GPU fallback).
+func (s *supervisor) setEngineInfo(info internal.EngineInfoEvent) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.model, s.device, s.language = info.Model, info.Device, info.Language
+}
+
+func (s *supervisor) recordTranscription(transcription internal.TranscriptionEvent) {
+	s.mu.Lock()
+	s.transcriptions++
+	s.markActive()
+	s.publish(internal.ControlEvent{Type: "transcription", Text: transcription.Text, App: transcription.App})
+	s.mu.Unlock()
+
+	s.notify(textPreview(transcription.Text), "transcribed")
+}
+
+// textPreview shortens a transcription for a notification.
+func textPreview(text string) string {
+	const maxRunes = 80
+	runes := []rune(strings.TrimSpace(text))
+	if len(runes) <= maxRunes {
+		return string(runes)
+	}
+	return strings.TrimSpace(string(runes[:maxRunes-1])) + "…"
+}
+
+// subscribe registers an attach client; it starts with the current state.
+func (s *supervisor) subscribe() (<-chan internal.ControlEvent, func()) {
+	events := make(chan internal.ControlEvent, 64)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	events <- internal.ControlEvent{Type: "state", State: s.state}
+	if s.subscribers == nil {
+		// Already shut down
+		close(events)
+		return events, func() {}
+	}
+	s.subscribers[events] = struct{}{}
+
+	return events, func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if _, ok := s.subscribers[events]; ok {
+			delete(s.subscribers, events)
+			close(events)
+		}
+	}
+}
+
+// publish fans an event out to subscribers, dropping it for any that fall behind. Requires s.mu.
+func (s *supervisor) publish(event internal.ControlEvent) {
+	for events := range s.subscribers {
+		select {
+		case events <- event:
+		default:
+		}
+	}
+}
+
+// closeSubscribers ends every attach session once the instance is gone.
+func (s *supervisor) closeSubscribers() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for events := range s.subscribers {
+		close(events)
+	}
+	s.subscribers = nil
+}
+
+func (s *supervisor) status() *internal.StatusInfo {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	state := s.state
+	if state == "silence" {
+		// Trailing silence is still part of an utterance being recorded
+		state = "listening"
+	}
+
+	language := s.language
+	if language == "" {
+		language = "auto"
+	}
+
+	// Copies, the answer is encoded after s.mu is released
+	audio, vad, transcription := s.cfg.Audio, s.cfg.VAD, s.cfg.Transcription
+
+	return &internal.StatusInfo{
+		Running:        true,
+		State:          state,
+		Model:          s.model,
+		Device:         s.device,
+		Language:       language,
+		Profile:        s.opts.profile,
+		CaptureMode:    s.cfg.CaptureMode,
+		PID:            os.Getpid(),
+		EnginePID:      s.cmd.Process.Pid,
+		Manager:        processManager(),
+		UptimeSeconds:  int64(time.Since(s.startedAt).Seconds()),
+		Transcriptions: s.transcriptions,
+		Restarts:       s.restarts,
+		StopTimeout:    s.cfg.StopTimeout,
+		Audio:          &audio,
+		VAD:            &vad,
+		Transcription:  &transcription,
+	}
+}
+
+// processManager describes what keeps this instance alive.
+func processManager() string {
+	switch {
+	case internal.IsSystemdService():
+		return "systemd"
+	case internal.IsDaemon():
+		return "daemon"
+	default:
+		return "foreground"
+	}
+}
+
+// waitState blocks until the engine reports a state accepted by want, or the timeout expires.
+func (s *supervisor) waitState(want func(string) bool, timeout time.Duration) (string, bool) {
+	deadline := time.After(timeout)
+	for {
+		s.mu.Lock()
+		state, changed := s.state, s.changed
+		s.mu.Unlock()
+
+		if want(state) {
+			return state, true
+		}
+
+		select {
+		case <-changed:
+		case <-deadline:
+			return state, false
+		}
+	}
+}
+
+// requestShutdown asks run to stop the engine and return. Safe to call more than once.
+func (s *supervisor) requestShutdown() {
+	s.stopOnce.Do(func() { close(s.shutdown) })
+}
+
+// shuttingDown reports whether requestShutdown was called.
+func (s *supervisor) shuttingDown() bool {
+	select {
+	case <-s.shutdown:
+		return true
+	default:
+		return false
+	}
+}
+
+func (s *supervisor) handle(req internal.ControlRequest) internal.ControlResponse {
+	switch req.Cmd {
+	case "status":
+		// Read-only, must not queue behind a pause waiting for its ack
+		info := s.status()
+		return internal.ControlResponse{OK: true, State: info.State, Status: info}
+	case "hold", "release":
+		// Bound to a key, must not wait behind a model reload
+		return s.pushToTalk(req.Cmd)
+	case "once":
+		// Waits for speech, must not hold up pause/resume meanwhile
+		return s.once(req.Args)
+	}
+
+	s.ctlMu.Lock()
+	defer s.ctlMu.Unlock()
+
+	switch req.Cmd {
+	case "pause":
+		return s.pause()
+	case "resume":
+		return s.resume()
+	case "toggle":
+		if s.currentState() == "paused" {
+			return s.resume()
+		}
+		return s.pause()
+	case "set":
+		if len(req.Args) != 2 {
+			return internal.ControlResponse{Error: "usage: set <setting> <value>"}
+		}
+		return s.set(req.Args[0], req.Args[1])
+	case "reload":
+		return s.reload()
+	case "stop":
+		// Answer only once the engine is actually gone
+		s.requestShutdown()
+		<-s.done
+		return internal.ControlResponse{OK: true, State: s.stopResult}
+	default:
+		return internal.ControlResponse{Error: "unknown command: " + req.Cmd}
+	}
+}
+
+func (s *supervisor) pause() internal.ControlResponse {
+	return s.signalAndWait(syscall.SIGUSR1, "paused", func(state string) bool { return state == "paused" })
+}
+
+func (s *supervisor) resume() internal.ControlResponse {
+	return s.signalAndWait(syscall.SIGUSR2, "resumed", func(state string) bool { return state != "paused" })
+}
+
+// signalAndWait signals the engine and waits for it to acknowledge the new state.
+func (s *supervisor) signalAndWait(sig syscall.Signal, verb string, done func(string) bool) internal.ControlResponse {
+	state := s.currentState()
+	if state == "initializing" {
+		// Signal handlers are not installed until the model has loaded
+		return internal.ControlResponse{State: state, Error: "engine is still initializing"}
+	}
+	if done(state) {
+		return internal.ControlResponse{OK: true, State: state}
+	}
+
+	if err := s.engineProcess().Signal(sig); err != nil {
+		return internal.ControlResponse{State: state, Error: err.Error()}
+	}
+
+	// The watchdog resets the state to initializing if the engine dies meanwhile
+	acked := func(state string) bool { return done(state) || state == "initializing" }
+	state, ok := s.waitState(func(state string) bool { return acked(state) || state == "processing" }, ackTimeout)
+	if ok && !acked(state) {
+		// Mid-transcription: the engine gets to the signal once it is done,
+		// which the watchdog allows processingTimeout for
+		state, ok = s.waitState(acked, processingTimeout+ackTimeout)
+	}
+	if !ok {
+		return internal.ControlResponse{State: state, Error: "engine did not confirm it " + verb}
+	}
+	if state == "initializing" {
+		return internal.ControlResponse{State: state, Error: "engine restarted before it " + verb}
+	}
+	return internal.ControlResponse{OK: true, State: state}
+}
@@ -3,38 +3,33 @@ package commands
 import (
 	"fmt"
 	"os"
-	"strings"
-	"syscall"
 
 	"yappers-of-linux/internal"
 )
 
 func Toggle(args []string) {
-	cfg := internal.LoadConfig()
+	p := mustParseFlags("toggle", startFlagSpecs, args)
+	if len(p.args) > 0 {
+		fmt.Fprintf(os.Stderr, "unexpected argument: %s\n", p.args[0])
+		os.Exit(1)
+	}
+	if len(args) > 0 && internal.InstanceRunning() {
+		// Pausing or resuming has nothing to apply them to
+		fmt.Fprintln(os.Stderr, "start options only apply when toggle starts yap (yap stop first, or drop them)")
+		os.Exit(1)
+	}
 
-	pid, err := internal.GetPID()
-	if err != nil {
-		os.Remove(internal.GetStateFile())
+	resp, err := internal.SendControlTimeout(signalReplyTimeout, "toggle")
+	if err == internal.ErrNotRunning {
 		Start(args)
 		return
 	}
+	if err != nil {
+		exitControlError(err)
+	}
 
-	stateData, err := os.ReadFile(internal.GetStateFile())
-	state := strings.TrimSpace(string(stateData))
-
-	if err == nil && state == "paused" {
-		if err := syscall.Kill(pid, syscall.SIGUSR2); err != nil {
-			fmt.Fprintf(os.Stderr, "failed to resume: %v\n", err)
-			os.Exit(1)
-		}
-		os.WriteFile(internal.GetStateFile(), []byte("active"), 0644)
-		internal.Notify("Yapping started", "start", cfg)
-	} else {
-		if err := syscall.Kill(pid, syscall.SIGUSR1); err != nil {
-			fmt.Fprintf(os.Stderr, "failed to pause: %v\n", err)
-			os.Exit(1)
-		}
-		os.WriteFile(internal.GetStateFile(), []byte("paused"), 0644)
-		internal.Notify("Yapping paused", "pause", cfg)
+	if !resp.OK {
+		fmt.Fprintf(os.Stderr, "failed to toggle: %s\n", resp.Error)
+		os.Exit(1)
 	}
 }
@@ -0,0 +1,202 @@
+package commands
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"yappers-of-linux/internal"
+)
+
+var transcriptFormats = []string{"txt", "srt", "vtt", "json"}
+
+// transcript is what `main.py --transcribe` prints.
+type transcript struct {
+	Language string              `json:"language"`
+	Duration float64             `json:"duration"`
+	Segments []transcriptSegment `json:"segments"`
+}
+
+type transcriptSegment struct {
+	Start      float64 `json:"start"`
+	End        float64 `json:"end"`
+	Text       string  `json:"text"`
+	Confidence float64 `json:"confidence"` // 0-1, from the segment's average log probability
+}
+
+// transcribeFlagSpecs are the options of transcribe: start options, minus
+// --daemon, choose the model and language.
+var (
+	transcribeOptions = []flagSpec{
+		{names: []string{"--format", "-f"}, arg: "X", values: func() []string { return transcriptFormats }, help: "txt, srt, vtt or json (default: from --output, else txt)"},
+		{names: []string{"--output", "-o"}, arg: "PATH", help: "Write to a file instead of stdout"},
+	}
+	transcribeFlagSpecs = slices.Concat(transcribeOptions, withoutFlags(startFlagSpecs, "--daemon"))
+)
+
+// Transcribe implements `yap transcribe FILE|- [--format F] [--output PATH] [start options]`.
+func Transcribe(args []string) {
+	p := mustParseFlags("transcribe", transcribeFlagSpecs, args)
+	if len(p.args) != 1 {
+		fmt.Fprintln(os.Stderr, "usage: yap transcribe FILE|- [--format txt|srt|vtt|json] [--output PATH] [options]")
+		os.Exit(1)
+	}
+
+	input := p.args[0]
+	format, _ := p.value("--format")
+	output, _ := p.value("--output")
+	startArgs := p.raw(startFlagSpecs)
+
+	// Default to the output file's extension
+	if format == "" {
+		format = strings.TrimPrefix(filepath.Ext(output), ".")
+		if !internal.IsOneOf(format, transcriptFormats) {
+			format = "txt"
+		}
+	}
+	if !internal.IsOneOf(format, transcriptFormats) {
+		fmt.Fprintf(os.Stderr, "unknown format: %s (%s)\n", format, strings.Join(transcriptFormats, ", "))
+		os.Exit(1)
+	}
+
+	if input != "-" {
+		if _, err := os.Stat(input); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	}
+
+	if err := internal.SelfHeal(); err != nil {
+		fmt.Fprintf(os.Stderr, "setup failed: %v\n", err)
+		os.Exit(1)
+	}
+
+	cfg := internal.LoadConfig()
+	opts, err := resolveStartOptions(cfg, startArgs)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	result, err := runFileTranscription(input, cfg, opts)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if len(result.Segments) == 0 {
+		fmt.Fprintln(os.Stderr, "no speech found")
+	}
+
+	rendered := renderTranscript(result, format)
+	if output == "" {
+		fmt.Print(rendered)
+		return
+	}
+	if err := os.WriteFile(output, []byte(rendered), 0644); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to write %s: %v\n", output, err)
+		os.Exit(1)
+	}
+	fmt.Fprintf(os.Stderr, "wrote %s\n", output)
+}
+
+// runFileTranscription runs the engine in file mode with the same model,
+// compute type selection and [transcription] settings as `yap start`.
+func runFileTranscription(input string, cfg *internal.Config, opts startOptions) (transcript, error) {
+	systemDir, err := internal.GetSystemDir()
+	if err != nil {
+		return transcript{}, fmt.Errorf("failed to get system directory: %w", err)
+	}
+
+	venvPython := filepath.Join(systemDir, "venv", "bin", "python")
+	script := filepath.Join(systemDir, "main.py")
+
+	pythonArgs := []string{script, "--model", opts.model, "--device", opts.device, "--language", opts.language}
+	if opts.fastMode {
+		pythonArgs = append(pythonArgs, "--fast")
+	}
+	pythonArgs = append(pythonArgs, transcriptionArgs(cfg)...)
+	pythonArgs = append(pythonArgs, "--transcribe", input)
+
+	cmd := exec.Command(venvPython, pythonArgs...)
+	cmd.Env = engineEnv(systemDir)
+	if input == "-" {
+		cmd.Stdin = os.Stdin
+	}
+
+	var stdout bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = os.Stderr
+
+	events, eventsOut, err := eventPipe(cmd)
+	if err != nil {
+		return transcript{}, err
+	}
+
+	if err := cmd.Start(); err != nil {
+		return transcript{}, fmt.Errorf("failed to start engine: %w", err)
+	}
+	eventsOut.Close()
+
+	var fatal string
+	internal.ReadEngineEvents(events, func(event internal.EngineEvent) {
+		switch e := event.(type) {
+		case internal.LoadingEvent:
+			fmt.Fprintf(os.Stderr, "%s...\n", e.Stage)
+		case internal.FatalEvent:
+			fatal = e.Message
+		}
+	})
+
+	if err := cmd.Wait(); err != nil {
+		if fatal != "" {
+			return transcript{}, fmt.Errorf("transcription failed: %s", fatal)
+		}
+		return transcript{}, fmt.Errorf("transcription failed (%v)", err)
+	}
+
+	var result transcript
+	if err := json.Unmarshal(stdout.Bytes(), &result); err != nil {
+		return transcript{}, fmt.Errorf("unexpected engine output: %w", err)
+	}
+	return result, nil
+}
+
+func renderTranscript(t transcript, format string) string {
+	var b strings.Builder
+
+	switch format {
+	case "json":
+		if t.Segments == nil {
+			t.Segments = []transcriptSegment{}
+		}
+		data, _ := json.MarshalIndent(t, "", "  ")
+		b.Write(data)
+		b.WriteString("\n")
+	case "srt":
+		for i, seg := range t.Segments {
+			fmt.Fprintf(&b, "%d\n%s --> %s\n%s\n\n", i+1, formatTimestamp(seg.Start, ","), formatTimestamp(seg.End, ","), seg.Text)
+		}
+	case "vtt":
+		b.WriteString("WEBVTT\n\n")
+		for _, seg := range t.Segments {
+			fmt.Fprintf(&b, "%s --> %s\n%s\n\n", formatTimestamp(seg.Start, "."), formatTimestamp(seg.End, "."), seg.Text)
+		}
+	default:
+		for _, seg := range t.Segments {
+			b.WriteString(seg.Text + "\n")
+		}
+	}
+
+	return b.String()
+}
+
+// formatTimestamp renders seconds as HH:MM:SS followed by sep and milliseconds.
+func formatTimestamp(seconds float64, sep string) string {
+	ms := int64(seconds*1000 + 0.5)
+	return fmt.Sprintf("%02d:%02d:%02d%s%03d", ms/3600000, ms/60000%60, ms/1000%60, sep, ms%1000)
+}
@@ -0,0 +1,178 @@
+package commands
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"syscall"
+	"time"
+	"unsafe"
+
+	"yappers-of-linux/internal"
+)
+
+// typingSample has shifted keys and punctuation, which trip up some backends.
+const typingSample = "Hello from yap: typing works, 100%!"
+
+var typingTestFlagSpecs = []flagSpec{
+	{names: []string{"--backend"}, arg: "X", values: typingBackendValues, help: "Test these backends instead of typing_backend (e.g. ydotool,uinput)"},
+}
+
+// Typing implements `yap typing`: each backend and whether it can work here.
+func Typing() {
+	cfg := internal.LoadConfig()
+	chain, _ := internal.TypingChain(cfg.TypingBackend)
+
+	session := internal.SessionType()
+	if session == "" {
+		session = "unknown"
+	}
+	fmt.Printf("session %s, typing_backend = %q\n\n", session, cfg.TypingBackend)
+
+	for _, b := range internal.TypingBackends {
+		status := "ok"
+		if err := b.Check(); err != nil {
+			status = err.Error()
+		}
+		fmt.Printf("  %-8s %s\n", b.Name, status)
+		fmt.Printf("  %-8s %s\n", "", b.Summary)
+	}
+
+	fmt.Println()
+	if len(chain) == 0 {
+		fmt.Println("none of the configured backends can type")
+		return
+	}
+	fmt.Printf("tried in order: %s\n", strings.Join(chain, ", "))
+}
+
+// TypingTest implements `yap typing test`: each backend types a sample line
+// into this terminal, which reads it back.
+func TypingTest(args []string) {
+	p := mustParseFlags("typing test", typingTestFlagSpecs, args)
+	if len(p.args) > 0 {
+		fmt.Fprintf(os.Stderr, "unexpected argument: %s\n", p.args[0])
+		os.Exit(1)
+	}
+	if !isTerminal(os.Stdin) {
+		fmt.Fprintln(os.Stderr, "yap typing test types into its terminal, run it in one")
+		os.Exit(1)
+	}
+
+	setting := internal.LoadConfig().TypingBackend
+	if backend, ok := p.value("--backend"); ok {
+		setting = backend
+	}
+	for _, name := range strings.Split(setting, ",") {
+		if !slices.Contains(internal.TypingBackendNames(), strings.TrimSpace(name)) {
+			fmt.Fprintf(os.Stderr, "unknown typing backend: %s (%s)\n", name, strings.Join(internal.TypingBackendNames(), ", "))
+			os.Exit(1)
+		}
+	}
+	candidates := internal.TypingCandidates(setting)
+
+	if err := internal.SelfHeal(); err != nil {
+		fmt.Fprintf(os.Stderr, "setup failed: %v\n", err)
+		os.Exit(1)
+	}
+
+	lines := make(chan string)
+	go func() {
+		scanner := bufio.NewScanner(os.Stdin)
+		for scanner.Scan() {
+			lines <- scanner.Text()
+		}
+	}()
+
+	fmt.Println("Each backend types a line into this terminal; keep it focused and hands off the keyboard.")
+	tested, failed := 0, 0
+	for _, b := range candidates {
+		if err := b.Check(); err != nil {
+			fmt.Printf("%-8s skipped: %v\n", b.Name, err)
+			continue
+		}
+		tested++
+		if !testTypingBackend(b.Name, lines) {
+			failed++
+		}
+	}
+	if tested == 0 || failed > 0 {
+		os.Exit(1)
+	}
+}
+
+// isTerminal reports whether f is a terminal (has terminal attributes).
+func isTerminal(f *os.File) bool {
+	var termios syscall.Termios
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, f.Fd(), syscall.TCGETS, uintptr(unsafe.Pointer(&termios)))
+	return errno == 0
+}
+
+// testTypingBackend has the engine type typingSample and Enter with one
+// backend, and checks the line that arrives.
+func testTypingBackend(name string, lines <-chan string) bool {
+	fmt.Printf("%-8s ", name)
+	// Let go of the keys that ran the command first
+	time.Sleep(500 * time.Millisecond)
+
+	warning, err := runTypingEngine(name)
+	if err != nil {
+		if warning == "" {
+			warning = err.Error()
+		}
+		fmt.Println(warning)
+		return false
+	}
+
+	select {
+	case line := <-lines:
+		if strings.TrimSpace(line) != typingSample {
+			fmt.Printf("%-8s typed %q instead (keyboard layout?)\n", "", line)
+			return false
+		}
+		fmt.Printf("%-8s ok\n", "")
+		return true
+	case <-time.After(3 * time.Second):
+		fmt.Printf("\n%-8s nothing arrived (was this terminal focused?)\n", "")
+		return false
+	}
+}
+
+// runTypingEngine runs main.py --type-test with a single backend and returns
+// the warning it reported on failure.
+func runTypingEngine(backend string) (string, error) {
+	systemDir, err := internal.GetSystemDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get system directory: %w", err)
+	}
+
+	venvPython := filepath.Join(systemDir, "venv", "bin", "python")
+	script := filepath.Join(systemDir, "main.py")
+
+	cmd := exec.Command(venvPython, script, "--typing-backends", backend, "--type-test", typingSample)
+	cmd.Env = engineEnv(systemDir)
+
+	events, eventsOut, err := eventPipe(cmd)
+	if err != nil {
+		return "", err
+	}
+	if err := cmd.Start(); err != nil {
+		return "", fmt.Errorf("failed to start engine: %w", err)
+	}
+	eventsOut.Close()
+
+	var warning string
+	internal.ReadEngineEvents(events, func(event internal.EngineEvent) {
+		switch e := event.(type) {
+		case internal.WarningEvent:
+			warning = e.Message
+		case internal.FatalEvent:
+			warning = e.Message
+		}
+	})
+	return warning, cmd.Wait()
+}
@@ -13,15 +13,12 @@ var Version = "0.0.0"
 
 var updater = satellite.New("DeprecatedLuar", "yappers-of-linux")
 
+var updateFlagSpecs = []flagSpec{
+	{names: []string{"--force", "-f"}, help: "Reinstall even when up to date"},
+}
+
 func Update(args []string) {
-	// Check for --force flag
-	force := false
-	for _, arg := range args {
-		if arg == "--force" || arg == "-f" {
-			force = true
-			break
-		}
-	}
+	force := mustParseFlags("update", updateFlagSpecs, args).has("--force")
 
 	if force {
 		fmt.Println("Force update enabled. Installing latest version...")
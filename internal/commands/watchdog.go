@@ -0,0 +1,313 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"syscall"
+	"time"
+
+	"yappers-of-linux/internal"
+)
+
+const (
+	minRestartDelay   = 1 * time.Second
+	maxRestartDelay   = 60 * time.Second
+	healthyRunTime    = 5 * time.Minute  // a run this long resets the backoff
+	crashWindow       = 5 * time.Minute  // crashes counted towards the crash-loop limit
+	maxCrashes        = 5                // restarts allowed within crashWindow
+	audioStallTimeout = 30 * time.Second // no heartbeat while listening
+	processingTimeout = 2 * time.Minute  // stuck transcribing or typing
+	readyTimeout      = 10 * time.Minute // model download and warmup after a restart
+)
+
+func (s *supervisor) engineProcess() *os.Process {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.cmd.Process
+}
+
+// spawn starts a fresh engine process and returns a channel closed when it exits.
+func (s *supervisor) spawn() (<-chan struct{}, error) {
+	// Restarts pick up settings changed by reload and yap set
+	args := append(engineArgs(s.script, s.config(), s.options()), "--control-fd", "3")
+
+	cmd := exec.Command(s.python, args...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.Stdin = os.Stdin
+	cmd.Env = s.env
+	// Its own process group: Ctrl+C reaches only the supervisor, which then
+	// stops the engine with a single SIGTERM (a second one would abort the flush)
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+
+	// Commands go in on fd 3
+	engineOut, engineIn, err := os.Pipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create command pipe: %w", err)
+	}
+	cmd.ExtraFiles = []*os.File{engineOut}
+
+	// Events come back on fd 4
+	events, eventsOut, err := eventPipe(cmd)
+	if err != nil {
+		engineOut.Close()
+		engineIn.Close()
+		return nil, err
+	}
+
+	if err := cmd.Start(); err != nil {
+		engineOut.Close()
+		engineIn.Close()
+		events.Close()
+		eventsOut.Close()
+		return nil, err
+	}
+	engineOut.Close()
+	eventsOut.Close()
+
+	s.mu.Lock()
+	if s.engineIn != nil {
+		s.engineIn.Close()
+	}
+	s.cmd = cmd
+	s.engineIn = engineIn
+	s.failPending("engine restarted")
+	s.mu.Unlock()
+	s.setState("initializing")
+
+	scanned := make(chan struct{})
+	go func() {
+		internal.ReadEngineEvents(events, s.handleEvent)
+		events.Close()
+		close(scanned)
+	}()
+
+	exited := make(chan struct{})
+	go func() {
+		// Apply every event before reporting the exit
+		<-scanned
+		cmd.Wait()
+
+		// Nobody is left to answer requests sent to this engine
+		s.mu.Lock()
+		if s.cmd == cmd {
+			s.failPending("engine exited")
+		}
+		s.mu.Unlock()
+		close(exited)
+	}()
+
+	return exited, nil
+}
+
+// handleEvent applies one event from the engine's event stream.
+func (s *supervisor) handleEvent(event internal.EngineEvent) {
+	switch e := event.(type) {
+	case internal.ReadyEvent:
+		s.notify("Yapping started", "start")
+		s.cue("ready")
+	case internal.StateEvent:
+		s.setState(e.State)
+	case internal.HeartbeatEvent:
+		s.heartbeat()
+	case internal.EngineInfoEvent:
+		s.setEngineInfo(e)
+	case internal.TranscriptionEvent:
+		s.recordTranscription(e)
+	case internal.ReplyEvent:
+		s.deliverReply(e)
+	case internal.LoadingEvent:
+		s.reportLoading(e)
+	case internal.WarningEvent:
+		// The engine already printed it; this is for attached clients
+		s.mu.Lock()
+		s.publish(internal.ControlEvent{Type: "warning", Text: e.Message})
+		s.mu.Unlock()
+		s.notify("Error: "+e.Message, "error")
+	case internal.FatalEvent:
+		s.mu.Lock()
+		s.fatal = e.Message
+		s.mu.Unlock()
+		s.notify("Error: "+e.Message, "error")
+	}
+}
+
+// run keeps the engine alive until a shutdown is requested, the engine exits
+// on its own, or it crashes too often. The first engine must already be spawned.
+func (s *supervisor) run(exited <-chan struct{}) {
+	defer close(s.done)
+
+	delay := minRestartDelay
+	var crashes []time.Time
+
+	for {
+		startedAt := time.Now()
+
+		reason := s.watch(exited)
+		if reason == "" {
+			return
+		}
+
+		if time.Since(startedAt) >= healthyRunTime {
+			delay = minRestartDelay
+		}
+
+		now := time.Now()
+		crashes = append(crashes, now)
+		for len(crashes) > 0 && now.Sub(crashes[0]) > crashWindow {
+			crashes = crashes[1:]
+		}
+		if len(crashes) > maxCrashes {
+			fmt.Fprintf(os.Stderr, "engine %s, giving up after %d restarts in %s\n", reason, maxCrashes, crashWindow)
+			s.notify("Yapping stopped: engine keeps crashing", "stop")
+			s.cue("error")
+			return
+		}
+
+		fmt.Fprintf(os.Stderr, "engine %s, restarting in %s\n", reason, delay)
+		s.notify(fmt.Sprintf("Engine %s, restarting", reason), "stop")
+		s.cue("error")
+
+		wasPaused := s.currentState() == "paused"
+		s.setState("initializing")
+
+		select {
+		case <-time.After(delay):
+		case <-s.shutdown:
+			s.notify("Yapping stopped", "stop")
+			s.cue("stop")
+			return
+		}
+		delay = min(delay*2, maxRestartDelay)
+
+		var err error
+		if exited, err = s.spawn(); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to restart engine: %v\n", err)
+			s.notify("Yapping stopped: engine failed to restart", "stop")
+			s.cue("error")
+			return
+		}
+
+		s.mu.Lock()
+		s.restarts++
+		s.mu.Unlock()
+
+		if wasPaused {
+			go s.restorePause()
+		}
+	}
+}
+
+// watch waits for the current engine to go away. It returns "" when the
+// supervisor should exit, or why the engine needs a restart.
+func (s *supervisor) watch(exited <-chan struct{}) string {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-exited:
+			s.mu.Lock()
+			state := s.cmd.ProcessState
+			fatal := s.fatal
+			s.fatal = ""
+			s.mu.Unlock()
+			if state.Success() && s.shuttingDown() {
+				// Stopped as asked, before the shutdown case got to it
+				return ""
+			}
+			if fatal != "" {
+				return "crashed (" + state.String() + ": " + fatal + ")"
+			}
+			if state.Success() {
+				return "exited unexpectedly"
+			}
+			return "crashed (" + state.String() + ")"
+
+		case <-s.shutdown:
+			// Send notification before cleanup
+			s.notify("Yapping stopped", "stop")
+			s.cue("stop")
+			s.stopEngine(exited)
+			return ""
+
+		case <-ticker.C:
+			if s.checkIdle() {
+				minutes := s.config().IdleStop
+				fmt.Fprintf(os.Stderr, "paused for %d min, stopping\n", minutes)
+				s.notify(fmt.Sprintf("Yapping stopped after %d min paused", minutes), "stop")
+				s.cue("stop")
+				s.stopEngine(exited)
+				return ""
+			}
+			if reason := s.stalled(); reason != "" {
+				s.engineProcess().Kill()
+				<-exited
+				return reason
+			}
+		}
+	}
+}
+
+// stopEngine lets the engine finish the in-flight utterance and exit on SIGTERM,
+// escalating to SIGKILL once stop_timeout runs out.
+func (s *supervisor) stopEngine(exited <-chan struct{}) {
+	timeout := time.Duration(s.config().StopTimeout) * time.Second
+
+	if err := s.engineProcess().Signal(syscall.SIGTERM); err != nil {
+		<-exited
+		return
+	}
+
+	select {
+	case <-exited:
+		s.mu.Lock()
+		if !s.cmd.ProcessState.Success() {
+			s.stopResult = "stopped (" + s.cmd.ProcessState.String() + ")"
+		}
+		s.mu.Unlock()
+	case <-time.After(timeout):
+		fmt.Fprintf(os.Stderr, "engine did not stop within %s, killing it\n", timeout)
+		s.engineProcess().Kill()
+		<-exited
+		s.mu.Lock()
+		s.stopResult = "killed"
+		s.mu.Unlock()
+	}
+}
+
+// stalled reports why a live engine looks stuck, or "" if it looks healthy.
+func (s *supervisor) stalled() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch s.state {
+	case "paused":
+		// No audio is expected
+		return ""
+	case "initializing":
+		if time.Since(s.stateSince) > readyTimeout {
+			return fmt.Sprintf("stuck loading the model for %s", readyTimeout)
+		}
+		return ""
+	case "processing":
+		if time.Since(s.stateSince) > processingTimeout {
+			return fmt.Sprintf("stuck processing for %s", processingTimeout)
+		}
+		return ""
+	default:
+		if time.Since(s.lastBeat) > audioStallTimeout {
+			return fmt.Sprintf("stalled (no audio for %s)", audioStallTimeout)
+		}
+		return ""
+	}
+}
+
+// restorePause re-applies a pause that was active when the engine went down.
+func (s *supervisor) restorePause() {
+	if _, ok := s.waitState(func(state string) bool { return state != "initializing" }, readyTimeout); !ok {
+		return
+	}
+	s.handle(internal.ControlRequest{Cmd: "pause"})
+}
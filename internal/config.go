@@ -1,57 +1,148 @@
 package internal
 
 import (
+	"errors"
+	"fmt"
+	"io/fs"
 	"path/filepath"
+	"sort"
 	"strings"
 
 	"github.com/BurntSushi/toml"
 )
 
 type NotificationConfig struct {
-	Events []string
-	Urgent bool
+	Events  []string
+	Urgency map[string]string // per event: "low", "normal" or "critical"
 }
 
 type Config struct {
+	ConfigVersion int    `toml:"config_version"` // see ConfigVersion and migrate.go
 	Notifications string `toml:"notifications"`
 	Model         string `toml:"model"`
 	Device        string `toml:"device"`
 	Language      string `toml:"language"`
 	FastMode      bool   `toml:"fast_mode"`
 	EnableTyping  bool   `toml:"enable_typing"`
+	TypingBackend string `toml:"typing_backend"` // "auto", or backends to try in order, see TypingChain
 	OutputFile    bool   `toml:"output_file"`
-	Timeout       int    `toml:"timeout"`
+	Timeout       int    `toml:"timeout"`   // seconds without output before auto-pause
+	IdleStop      int    `toml:"idle_stop"` // minutes paused before a full stop
 	TCPPort       int    `toml:"tcp_port"`
+	StopTimeout   int    `toml:"stop_timeout"`
+	FlushOnStop   bool   `toml:"flush_on_stop"`
+	CaptureMode   string `toml:"capture_mode"` // "vad" or "ptt" (push-to-talk)
+	Profile       string `toml:"profile"`      // default profile, and the active one after ApplyProfile
+
+	// Sound cues, played by yap itself
+	Sounds SoundSettings `toml:"sounds"`
+
+	// Engine tuning, passed to the engine on start and reload
+	Audio         AudioSettings         `toml:"audio"`
+	VAD           VADSettings           `toml:"vad"`
+	Transcription TranscriptionSettings `toml:"transcription"`
+
+	// [[app]] tables, matched against the focused window for each utterance
+	Apps []AppRule `toml:"app"`
+
+	// [profiles.<name>] tables, each overriding any of the fields above
+	Profiles map[string]toml.Primitive `toml:"profiles"`
+
+	meta    toml.MetaData
+	sources map[string]string // settings not from config.toml or defaults, see Source
+}
+
+// SoundSettings is the [sounds] table: which events play a cue, see PlaySound.
+type SoundSettings struct {
+	Enabled  bool    `toml:"enabled" json:"enabled"`
+	Volume   float64 `toml:"volume" json:"volume"` // 0-1
+	Ready    bool    `toml:"ready" json:"ready"`   // listening began (start, resume)
+	Pause    bool    `toml:"pause" json:"pause"`
+	Stop     bool    `toml:"stop" json:"stop"`
+	Captured bool    `toml:"captured" json:"captured"` // an utterance ended and is being transcribed
+	Error    bool    `toml:"error" json:"error"`       // the engine crashed
+}
+
+// AudioSettings is the [audio] table: when an utterance starts and ends.
+type AudioSettings struct {
+	SilenceDuration float64 `toml:"silence_duration" json:"silence_duration"` // seconds of silence that end an utterance
+	PreBuffer       float64 `toml:"pre_buffer" json:"pre_buffer"`             // seconds kept from before speech was detected
+}
+
+// VADSettings is the [vad] table for webrtcvad speech detection.
+type VADSettings struct {
+	Aggressiveness int `toml:"aggressiveness" json:"aggressiveness"` // 0 (lenient) to 3 (strict)
+}
+
+// TranscriptionSettings is the [transcription] table for Whisper decoding.
+type TranscriptionSettings struct {
+	BeamSize         int     `toml:"beam_size" json:"beam_size"`
+	MinConfidence    float64 `toml:"min_confidence" json:"min_confidence"`         // average log-probability below which a segment is dropped
+	MinAudioDuration float64 `toml:"min_audio_duration" json:"min_audio_duration"` // seconds; shorter utterances are skipped
+}
+
+// AppRule is one [[app]] table: overrides for utterances dictated into windows
+// whose class (X11) or app_id (Wayland) matches. Unset fields keep the setting.
+type AppRule struct {
+	Match         string  `toml:"match" json:"match"` // glob, case-insensitive, e.g. "kitty" or "*telegram*"
+	EnableTyping  *bool   `toml:"enable_typing" json:"enable_typing,omitempty"`
+	Language      *string `toml:"language" json:"language,omitempty"`
+	TrailingSpace *bool   `toml:"trailing_space" json:"trailing_space,omitempty"` // default true
+	PressEnter    *bool   `toml:"press_enter" json:"press_enter,omitempty"`       // default false
 }
 
+// ParseNotifications reads the notifications setting: comma-separated events,
+// each optionally with its urgency ("error:critical"). A bare "urgent" makes
+// the events without one critical; alone it means "start:critical".
 func ParseNotifications(notifStr string) NotificationConfig {
+	nc := NotificationConfig{Events: []string{}, Urgency: map[string]string{}}
 	notifStr = strings.TrimSpace(notifStr)
 
 	// Handle disabled cases
 	if notifStr == "" || notifStr == "false" || notifStr == "disabled" {
-		return NotificationConfig{Events: []string{}, Urgent: false}
+		return nc
 	}
 
-	// Parse comma-separated values
-	parts := strings.Split(notifStr, ",")
-	events := []string{}
 	urgent := false
-
-	for _, part := range parts {
-		part = strings.TrimSpace(part)
-		if part == "urgent" {
+	for _, part := range strings.Split(notifStr, ",") {
+		event, urgency, ok := parseNotificationToken(part)
+		if !ok {
+			continue
+		}
+		if event == "urgent" {
 			urgent = true
-		} else if part == "start" || part == "pause" || part == "stop" {
-			events = append(events, part)
+			continue
+		}
+		nc.Events = append(nc.Events, event)
+		if urgency != "" {
+			nc.Urgency[event] = urgency
 		}
 	}
 
-	// Handle "urgent" alone as shorthand for "start,urgent"
-	if len(events) == 0 && urgent {
-		events = []string{"start"}
+	if len(nc.Events) == 0 && urgent {
+		nc.Events = []string{"start"}
+	}
+	if urgent {
+		for _, event := range nc.Events {
+			if nc.Urgency[event] == "" {
+				nc.Urgency[event] = "critical"
+			}
+		}
 	}
+	return nc
+}
 
-	return NotificationConfig{Events: events, Urgent: urgent}
+// parseNotificationToken splits "event[:urgency]" and reports whether both
+// parts are known. "urgent" is accepted for "critical".
+func parseNotificationToken(part string) (event, urgency string, ok bool) {
+	event, urgency, _ = strings.Cut(strings.TrimSpace(part), ":")
+	if urgency == "urgent" {
+		urgency = "critical"
+	}
+	if event == "urgent" {
+		return event, "", urgency == ""
+	}
+	return event, urgency, IsOneOf(event, NotificationEvents) && (urgency == "" || IsOneOf(urgency, NotificationUrgencies))
 }
 
 func (nc NotificationConfig) ShouldNotify(event string) bool {
@@ -63,36 +154,122 @@ func (nc NotificationConfig) ShouldNotify(event string) bool {
 	return false
 }
 
-func LoadConfig() *Config {
-	configDir, err := GetConfigDir()
-	if err != nil {
-		return &Config{
-			Notifications: "urgent",
-			Model:         "tiny",
-			Device:        "cpu",
-			Language:      "en",
-			FastMode:      false,
-			EnableTyping:  true,
-			OutputFile:    false,
-			Timeout:       0,
-		}
+// UrgencyOf returns the urgency of event's notifications, "normal" by default.
+func (nc NotificationConfig) UrgencyOf(event string) string {
+	if urgency := nc.Urgency[event]; urgency != "" {
+		return urgency
 	}
+	return "normal"
+}
 
-	configPath := filepath.Join(configDir, "config.toml")
-
-	cfg := &Config{
+// builtinDefaults is what a missing config.toml (or key) means.
+func builtinDefaults() *Config {
+	return &Config{
 		Notifications: "urgent",
 		Model:         "tiny",
 		Device:        "cpu",
 		Language:      "en",
 		FastMode:      false,
 		EnableTyping:  true,
+		TypingBackend: "auto",
 		OutputFile:    false,
 		Timeout:       0,
+		IdleStop:      0,
+		StopTimeout:   10,
+		FlushOnStop:   true,
+		CaptureMode:   "vad",
+		Sounds: SoundSettings{
+			Volume:   0.5,
+			Ready:    true,
+			Pause:    true,
+			Stop:     true,
+			Captured: true,
+			Error:    true,
+		},
+		Audio: AudioSettings{
+			SilenceDuration: 0.8,
+			PreBuffer:       1.5,
+		},
+		VAD: VADSettings{
+			Aggressiveness: 3,
+		},
+		Transcription: TranscriptionSettings{
+			BeamSize:         5,
+			MinConfidence:    -0.7,
+			MinAudioDuration: 0.7,
+		},
 	}
-	if _, err := toml.DecodeFile(configPath, cfg); err != nil {
-		return cfg
-	}
+}
 
+// LoadConfig reads config.toml, falling back to defaults if it is missing or invalid.
+func LoadConfig() *Config {
+	cfg, _ := ReadConfig()
 	return cfg
 }
+
+// ReadConfig is LoadConfig that also reports why config.toml could not be used.
+// The returned config is always usable (defaults on error); a missing file is not an error.
+func ReadConfig() (*Config, error) {
+	configDir, err := GetConfigDir()
+	if err != nil {
+		return builtinDefaults(), err
+	}
+
+	configPath := filepath.Join(configDir, "config.toml")
+
+	cfg := builtinDefaults()
+	meta, err := toml.DecodeFile(configPath, cfg)
+	if errors.Is(err, fs.ErrNotExist) {
+		return cfg, nil
+	}
+	if err != nil {
+		return cfg, err
+	}
+	cfg.meta = meta
+
+	return cfg, nil
+}
+
+// profileKey is the path of a possibly dotted setting inside [profiles.<name>],
+// for toml.MetaData.IsDefined.
+func profileKey(name, key string) []string {
+	return append([]string{"profiles", name}, strings.Split(key, ".")...)
+}
+
+// ProfileNames returns the names of all [profiles.<name>] tables, sorted.
+func (c *Config) ProfileNames() []string {
+	names := make([]string, 0, len(c.Profiles))
+	for name := range c.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ApplyProfile overlays the [profiles.<name>] table onto the config.
+// Only keys set in the profile change; an empty name is a no-op.
+func (c *Config) ApplyProfile(name string) error {
+	if name == "" {
+		return nil
+	}
+
+	profile, ok := c.Profiles[name]
+	if !ok {
+		if len(c.Profiles) == 0 {
+			return fmt.Errorf("unknown profile: %s (no profiles defined)", name)
+		}
+		return fmt.Errorf("unknown profile: %s (defined: %s)", name, strings.Join(c.ProfileNames(), ", "))
+	}
+
+	if err := c.meta.PrimitiveDecode(profile, c); err != nil {
+		return fmt.Errorf("invalid profile %s: %w", name, err)
+	}
+	c.Profile = name
+	for _, key := range ConfigKeys() {
+		if c.meta.IsDefined(profileKey(name, key)...) {
+			c.setSource(key, "profile "+name)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,359 @@
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+)
+
+// Accepted values, shared by config validation and the commands that set them
+var (
+	Models             = []string{"tiny", "base", "small", "medium", "large"}
+	Devices            = []string{"cpu", "gpu", "cuda"}
+	CaptureModes       = []string{"vad", "ptt"}
+	NotificationEvents = []string{"start", "pause", "stop", "transcribed", "error", "timeout", "loading"}
+
+	// Urgency levels of the notification spec, as notify-send -u takes them
+	NotificationUrgencies = []string{"low", "normal", "critical"}
+)
+
+// ConfigIssue is one problem found in config.toml.
+type ConfigIssue struct {
+	Line    int    // 0 if unknown
+	Key     string // dotted key, e.g. "profiles.notes.model"; "" for syntax errors
+	Message string
+	Warning bool // ignored when loading; errors make a setting unusable
+}
+
+func (i ConfigIssue) String() string {
+	s := i.Message
+	if i.Key != "" {
+		s = i.Key + ": " + s
+	}
+	if i.Warning {
+		s = "warning: " + s
+	}
+	return s
+}
+
+// HasConfigErrors reports whether any issue is more than a warning.
+func HasConfigErrors(issues []ConfigIssue) bool {
+	for _, issue := range issues {
+		if !issue.Warning {
+			return true
+		}
+	}
+	return false
+}
+
+// GetConfigFile returns the path of config.toml.
+func GetConfigFile() (string, error) {
+	configDir, err := GetConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(configDir, "config.toml"), nil
+}
+
+// CheckConfig validates config.toml strictly: syntax, unknown keys and every
+// value, profiles included. A missing file has no issues.
+func CheckConfig() ([]ConfigIssue, error) {
+	path, err := GetConfigFile()
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return checkConfigData(data), nil
+}
+
+// "toml: line 4 (last key "model"): incompatible types: ..."
+var tomlErrorLine = regexp.MustCompile(`^toml: line (\d+)(?: \(last key "([^"]*)"\))?: (.*)$`)
+
+func checkConfigData(data []byte) []ConfigIssue {
+	cfg := builtinDefaults()
+	meta, err := toml.Decode(string(data), cfg)
+	if err != nil {
+		// Nothing past the first syntax or type error can be trusted
+		issue := ConfigIssue{Message: strings.TrimPrefix(err.Error(), "toml: ")}
+		var perr toml.ParseError
+		if errors.As(err, &perr) {
+			issue.Line = perr.Position.Line
+			issue.Message = perr.Message
+		} else if m := tomlErrorLine.FindStringSubmatch(err.Error()); m != nil {
+			issue.Line, _ = strconv.Atoi(m[1])
+			issue.Key = m[2]
+			issue.Message = m[3]
+		}
+		return []ConfigIssue{issue}
+	}
+	cfg.meta = meta
+
+	lines := keyLines(data)
+	var issues []ConfigIssue
+	add := func(key, message string, warning bool) {
+		issues = append(issues, ConfigIssue{Line: lineOf(lines, key), Key: key, Message: message, Warning: warning})
+	}
+
+	for _, p := range checkValues(cfg) {
+		add(p.key, p.message, p.warning)
+	}
+	if cfg.Profile != "" {
+		if _, ok := cfg.Profiles[cfg.Profile]; !ok {
+			add("profile", fmt.Sprintf("unknown profile %q", cfg.Profile), false)
+		}
+	}
+
+	// A profile is checked as applied, but only for the keys it sets itself
+	for _, name := range cfg.ProfileNames() {
+		applied := *cfg
+		if err := meta.PrimitiveDecode(cfg.Profiles[name], &applied); err != nil {
+			add("profiles."+name, strings.TrimPrefix(err.Error(), "toml: "), false)
+			continue
+		}
+		for _, p := range checkValues(&applied) {
+			if meta.IsDefined(profileKey(name, p.key)...) {
+				add("profiles."+name+"."+p.key, p.message, p.warning)
+			}
+		}
+	}
+
+	// Profiles are decoded above, so whatever is left is unknown
+	for _, key := range meta.Undecoded() {
+		add(key.String(), "unknown setting", true)
+	}
+
+	sort.SliceStable(issues, func(i, j int) bool { return issues[i].Line < issues[j].Line })
+	return issues
+}
+
+// Validate checks the final values after Resolve, naming where a bad one came from.
+func (c *Config) Validate() error {
+	for _, p := range checkValues(c) {
+		if p.warning {
+			continue
+		}
+		if _, err := lookupConfigField(p.key); err != nil {
+			// Not a single setting (e.g. an [[app]] rule), so config.toml
+			return fmt.Errorf("%s: %s", p.key, p.message)
+		}
+		return fmt.Errorf("%s: %s (from %s)", p.key, p.message, c.Source(p.key))
+	}
+	return nil
+}
+
+type valueProblem struct {
+	key     string
+	message string
+	warning bool
+}
+
+// checkValues validates every setting of an already decoded config.
+func checkValues(cfg *Config) []valueProblem {
+	var problems []valueProblem
+	bad := func(key, format string, args ...any) {
+		problems = append(problems, valueProblem{key: key, message: fmt.Sprintf(format, args...)})
+	}
+
+	if !IsOneOf(cfg.Model, Models) {
+		bad("model", "unknown model %q (%s)", cfg.Model, strings.Join(Models, ", "))
+	}
+	if !IsOneOf(cfg.Device, Devices) {
+		bad("device", "unknown device %q (cpu, gpu)", cfg.Device)
+	}
+	if !IsOneOf(cfg.CaptureMode, CaptureModes) {
+		bad("capture_mode", "unknown capture mode %q (%s)", cfg.CaptureMode, strings.Join(CaptureModes, ", "))
+	}
+	for _, name := range strings.Split(cfg.TypingBackend, ",") {
+		if name = strings.TrimSpace(name); !IsOneOf(name, TypingBackendNames()) {
+			bad("typing_backend", "unknown typing backend %q (%s, or several comma-separated)", name, strings.Join(TypingBackendNames(), ", "))
+		}
+	}
+	if cfg.TCPPort < 0 || cfg.TCPPort > 65535 {
+		bad("tcp_port", "port %d out of range (1-65535, 0 = disabled)", cfg.TCPPort)
+	}
+	if cfg.Timeout < 0 {
+		bad("timeout", "must not be negative (0 = disabled)")
+	}
+	if cfg.IdleStop < 0 {
+		bad("idle_stop", "must not be negative (0 = disabled)")
+	}
+	if cfg.StopTimeout < 0 {
+		bad("stop_timeout", "must not be negative")
+	}
+	if v := cfg.Sounds.Volume; v < 0 || v > 1 {
+		bad("sounds.volume", "%g out of range (0-1)", v)
+	}
+	if d := cfg.Audio.SilenceDuration; d <= 0 || d > 10 {
+		bad("audio.silence_duration", "%g seconds out of range (more than 0, at most 10)", d)
+	}
+	if d := cfg.Audio.PreBuffer; d < 0 || d > 10 {
+		bad("audio.pre_buffer", "%g seconds out of range (0-10)", d)
+	}
+	if a := cfg.VAD.Aggressiveness; a < 0 || a > 3 {
+		bad("vad.aggressiveness", "%d out of range (0-3)", a)
+	}
+	if b := cfg.Transcription.BeamSize; b < 1 || b > 20 {
+		bad("transcription.beam_size", "%d out of range (1-20)", b)
+	}
+	if c := cfg.Transcription.MinConfidence; c > 0 {
+		bad("transcription.min_confidence", "%g is a log-probability and must not be positive (e.g. -0.7)", c)
+	}
+	if d := cfg.Transcription.MinAudioDuration; d < 0 || d > 10 {
+		bad("transcription.min_audio_duration", "%g seconds out of range (0-10)", d)
+	}
+
+	for i, rule := range cfg.Apps {
+		key := fmt.Sprintf("app.%d.match", i+1)
+		if rule.Match == "" {
+			bad(key, "missing (the window class or app_id to match)")
+		} else if _, err := path.Match(strings.ToLower(rule.Match), ""); err != nil {
+			bad(key, "invalid pattern %q", rule.Match)
+		}
+	}
+
+	if cfg.ConfigVersion > ConfigVersion {
+		problems = append(problems, valueProblem{
+			key:     "config_version",
+			message: fmt.Sprintf("written by a newer yap (version %d, this one knows %d), some settings may be ignored", cfg.ConfigVersion, ConfigVersion),
+			warning: true,
+		})
+	}
+
+	for _, event := range unknownNotificationEvents(cfg.Notifications) {
+		problems = append(problems, valueProblem{
+			key:     "notifications",
+			message: fmt.Sprintf("unknown notification %q (events: %s, each optionally :low, :normal or :critical)", event, strings.Join(NotificationEvents, ", ")),
+			warning: true,
+		})
+	}
+
+	if cfg.Sounds.Enabled && SoundPlayer() == nil {
+		problems = append(problems, valueProblem{
+			key:     "sounds.enabled",
+			message: "no player found for sound cues (install pw-play, paplay or aplay)",
+			warning: true,
+		})
+	}
+
+	return problems
+}
+
+// unknownNotificationEvents returns the tokens ParseNotifications would ignore:
+// unknown events or urgencies.
+func unknownNotificationEvents(notifStr string) []string {
+	notifStr = strings.TrimSpace(notifStr)
+	if notifStr == "" || notifStr == "false" || notifStr == "disabled" {
+		return nil
+	}
+
+	var unknown []string
+	for _, part := range strings.Split(notifStr, ",") {
+		if _, _, ok := parseNotificationToken(part); !ok {
+			unknown = append(unknown, strings.TrimSpace(part))
+		}
+	}
+	return unknown
+}
+
+// IsOneOf reports whether value is in allowed.
+func IsOneOf(value string, allowed []string) bool {
+	for _, a := range allowed {
+		if a == value {
+			return true
+		}
+	}
+	return false
+}
+
+// keyLines maps dotted keys to the line they are set on. It understands the
+// subset of TOML config.toml uses: tables, arrays of tables (numbered from 1,
+// e.g. app.2.match) and bare or quoted keys.
+func keyLines(data []byte) map[string]int {
+	lines := make(map[string]int)
+	arrays := make(map[string]int)
+	table, array := "", ""
+
+	for i, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		if strings.HasPrefix(line, "[") {
+			end := strings.Index(line, "]")
+			if end < 0 {
+				continue
+			}
+			table, array = normalizeKey(strings.Trim(line[:end], "[ ")), ""
+			if _, ok := lines[table]; !ok {
+				lines[table] = i + 1
+			}
+			if strings.HasPrefix(line, "[[") {
+				arrays[table]++
+				array = table
+				table = fmt.Sprintf("%s.%d", table, arrays[table])
+				lines[table] = i + 1
+			}
+			continue
+		}
+
+		eq := strings.Index(line, "=")
+		if eq <= 0 {
+			continue
+		}
+		key := normalizeKey(line[:eq])
+		keys := []string{key}
+		if table != "" {
+			keys[0] = table + "." + key
+		}
+		if array != "" {
+			// Unknown keys of an array entry are reported without its number
+			keys = append(keys, array+"."+key)
+		}
+		for _, k := range keys {
+			if _, ok := lines[k]; !ok {
+				lines[k] = i + 1
+			}
+		}
+	}
+
+	return lines
+}
+
+func normalizeKey(key string) string {
+	parts := strings.Split(key, ".")
+	for i, part := range parts {
+		parts[i] = strings.Trim(strings.TrimSpace(part), `"'`)
+	}
+	return strings.Join(parts, ".")
+}
+
+// lineOf finds the line of a key, or of the closest table that contains it.
+func lineOf(lines map[string]int, key string) int {
+	for key != "" {
+		if line, ok := lines[key]; ok {
+			return line
+		}
+		dot := strings.LastIndex(key, ".")
+		if dot < 0 {
+			break
+		}
+		key = key[:dot]
+	}
+	return 0
+}
@@ -0,0 +1,390 @@
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+)
+
+// configField is one setting of config.toml. Settings in a table such as
+// [audio] have a dotted key, e.g. "audio.silence_duration".
+type configField struct {
+	key   string
+	index []int // for reflect.Value.FieldByIndex
+	kind  reflect.Kind
+}
+
+// configFields lists the settings of Config in declaration order (profiles and
+// config_version, which only migrations write, excluded).
+func configFields() []configField {
+	return structFields(reflect.TypeOf(Config{}), "", nil)
+}
+
+func structFields(t reflect.Type, prefix string, index []int) []configField {
+	var fields []configField
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		key := f.Tag.Get("toml")
+		if key == "" || key == "config_version" {
+			continue
+		}
+		at := append(index[:len(index):len(index)], i)
+		switch f.Type.Kind() {
+		case reflect.String, reflect.Bool, reflect.Int, reflect.Float64:
+			fields = append(fields, configField{key: prefix + key, index: at, kind: f.Type.Kind()})
+		case reflect.Struct:
+			fields = append(fields, structFields(f.Type, prefix+key+".", at)...)
+		}
+	}
+	return fields
+}
+
+// splitConfigKey splits a dotted key into its table and the key within it.
+func splitConfigKey(key string) (table, leaf string) {
+	if dot := strings.LastIndex(key, "."); dot >= 0 {
+		return key[:dot], key[dot+1:]
+	}
+	return "", key
+}
+
+// joinTable nests a table in another, "" being the top level.
+func joinTable(parent, table string) string {
+	if parent == "" || table == "" {
+		return parent + table
+	}
+	return parent + "." + table
+}
+
+func lookupConfigField(key string) (configField, error) {
+	for _, f := range configFields() {
+		if f.key == key {
+			return f, nil
+		}
+	}
+	return configField{}, fmt.Errorf("unknown setting: %s (see yap config list)", key)
+}
+
+// ConfigKeys returns the names of all settings, in config.toml order.
+func ConfigKeys() []string {
+	var keys []string
+	for _, f := range configFields() {
+		keys = append(keys, f.key)
+	}
+	return keys
+}
+
+// Value returns the current value of a setting.
+func (c *Config) Value(key string) (any, error) {
+	f, err := lookupConfigField(key)
+	if err != nil {
+		return nil, err
+	}
+	return reflect.ValueOf(c).Elem().FieldByIndex(f.index).Interface(), nil
+}
+
+// FormatConfigValue renders a setting the way it is written in config.toml.
+func FormatConfigValue(v any) string {
+	switch v := v.(type) {
+	case string:
+		return tomlString(v)
+	case float64:
+		return tomlFloat(v)
+	}
+	return fmt.Sprint(v)
+}
+
+// tomlFloat keeps the decimal point, so 1.0 is not read back as an integer.
+func tomlFloat(f float64) string {
+	s := strconv.FormatFloat(f, 'f', -1, 64)
+	if !strings.Contains(s, ".") {
+		s += ".0"
+	}
+	return s
+}
+
+func tomlString(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, `"`, `\"`)
+	return `"` + s + `"`
+}
+
+// parseConfigValue type-checks a command-line value against the setting and
+// returns it as TOML.
+func parseConfigValue(f configField, value string) (string, error) {
+	switch f.kind {
+	case reflect.Bool:
+		if value != "true" && value != "false" {
+			return "", fmt.Errorf("%s must be true or false", f.key)
+		}
+		return value, nil
+	case reflect.Int:
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return "", fmt.Errorf("%s must be a whole number", f.key)
+		}
+		return strconv.Itoa(n), nil
+	case reflect.Float64:
+		x, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return "", fmt.Errorf("%s must be a number", f.key)
+		}
+		return tomlFloat(x), nil
+	default:
+		return tomlString(value), nil
+	}
+}
+
+// SetConfigValue writes key = value into config.toml, or into its
+// [profiles.<profile>] table, keeping comments and the order of other keys.
+// Keys of a section like audio.silence_duration go into its [audio] table.
+// The value must pass the same checks as yap config check; warnings about it
+// are returned.
+func SetConfigValue(profile, key, value string) ([]ConfigIssue, error) {
+	f, err := lookupConfigField(key)
+	if err != nil {
+		return nil, err
+	}
+	if profile != "" && key == "profile" {
+		return nil, fmt.Errorf("profile can only be set at the top level")
+	}
+	encoded, err := parseConfigValue(f, value)
+	if err != nil {
+		return nil, err
+	}
+
+	path, lines, err := readConfigLines()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := toml.Decode(strings.Join(lines, "\n"), &Config{}); err != nil {
+		return nil, fmt.Errorf("config.toml is invalid, fix it first (yap config check): %w", err)
+	}
+
+	base := profileTable(profile)
+	if i := findSettingLine(lines, base, key); i >= 0 {
+		lines[i] = replaceConfigValue(lines[i], encoded)
+	} else {
+		section, leaf := splitConfigKey(key)
+		lines = insertConfigKey(lines, joinTable(base, section), leaf+" = "+encoded)
+	}
+
+	data := []byte(strings.Join(lines, "\n"))
+	fullKey := joinTable(base, key)
+	var warnings []ConfigIssue
+	for _, issue := range checkConfigData(data) {
+		switch {
+		case issue.Key != fullKey && issue.Key != "":
+			// Problems elsewhere in the file are not this change's
+		case !issue.Warning:
+			return nil, fmt.Errorf("%s: %s", fullKey, issue.Message)
+		default:
+			warnings = append(warnings, issue)
+		}
+	}
+
+	return warnings, writeConfigFile(path, data)
+}
+
+// UnsetConfigValue removes a key from config.toml (or a profile), so the
+// default (or the top-level value) applies again.
+func UnsetConfigValue(profile, key string) error {
+	if _, err := lookupConfigField(key); err != nil {
+		return err
+	}
+
+	path, lines, err := readConfigLines()
+	if err != nil {
+		return err
+	}
+
+	i := findSettingLine(lines, profileTable(profile), key)
+	if i < 0 {
+		if profile != "" {
+			return fmt.Errorf("%s is not set in profile %s", key, profile)
+		}
+		return fmt.Errorf("%s is not set", key)
+	}
+	lines = append(lines[:i], lines[i+1:]...)
+
+	return writeConfigFile(path, []byte(strings.Join(lines, "\n")))
+}
+
+func profileTable(profile string) string {
+	if profile == "" {
+		return ""
+	}
+	return "profiles." + profile
+}
+
+func readConfigLines() (string, []string, error) {
+	path, err := GetConfigFile()
+	if err != nil {
+		return "", nil, err
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		// Start from the commented example, like a first run would
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return "", nil, err
+		}
+		data, err = defaultConfig, nil
+	}
+	if err != nil {
+		return "", nil, err
+	}
+	return path, strings.Split(string(data), "\n"), nil
+}
+
+// writeConfigFile replaces config.toml atomically, keeping its permissions.
+func writeConfigFile(path string, data []byte) error {
+	mode := fs.FileMode(0644)
+	if info, err := os.Stat(path); err == nil {
+		mode = info.Mode().Perm()
+	}
+
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, mode); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
+
+// configLine splits a line into its table header or key, if it has one.
+func configLine(line string) (header, key string) {
+	line = strings.TrimSpace(line)
+	if line == "" || strings.HasPrefix(line, "#") {
+		return "", ""
+	}
+	if strings.HasPrefix(line, "[") {
+		if end := strings.Index(line, "]"); end >= 0 {
+			return normalizeKey(strings.Trim(line[:end], "[ ")), ""
+		}
+		return "", ""
+	}
+	if eq := strings.Index(line, "="); eq > 0 {
+		return "", normalizeKey(line[:eq])
+	}
+	return "", ""
+}
+
+// findConfigKey returns the line setting key in table ("" for top level), or -1.
+func findConfigKey(lines []string, table, key string) int {
+	current := ""
+	for i, line := range lines {
+		header, k := configLine(line)
+		if header != "" {
+			current = header
+		} else if k == key && current == table {
+			return i
+		}
+	}
+	return -1
+}
+
+// findSettingLine finds a possibly dotted setting in base ("" for top level or
+// a profile table), written either in its own table ([audio] silence_duration)
+// or as a dotted key (audio.silence_duration), or returns -1.
+func findSettingLine(lines []string, base, key string) int {
+	section, leaf := splitConfigKey(key)
+	if i := findConfigKey(lines, joinTable(base, section), leaf); i >= 0 || section == "" {
+		return i
+	}
+	return findConfigKey(lines, base, key)
+}
+
+// insertConfigKey adds a line after the last key of table, creating the table
+// at the end of the file if it does not exist yet.
+func insertConfigKey(lines []string, table, entry string) []string {
+	current := ""
+	last := -1
+	found := table == ""
+	for i, line := range lines {
+		header, k := configLine(line)
+		if header != "" {
+			current = header
+			if current == table {
+				found = true
+				last = i
+			}
+		} else if k != "" && current == table {
+			last = i
+		}
+	}
+
+	if !found {
+		// Drop trailing blank lines, then start the table after one
+		for len(lines) > 0 && strings.TrimSpace(lines[len(lines)-1]) == "" {
+			lines = lines[:len(lines)-1]
+		}
+		if len(lines) > 0 {
+			lines = append(lines, "")
+		}
+		return append(lines, "["+table+"]", entry, "")
+	}
+
+	if last < 0 {
+		// Top level without keys: ahead of the first table
+		at := len(lines)
+		for i, line := range lines {
+			if header, _ := configLine(line); header != "" {
+				at = i
+				break
+			}
+		}
+		if at == len(lines) && at > 0 && lines[at-1] == "" {
+			// Keep the final newline last
+			at--
+		}
+		return slices.Insert(lines, at, entry)
+	}
+
+	return slices.Insert(lines, last+1, entry)
+}
+
+// replaceConfigValue swaps the value of a key = value line, keeping a trailing
+// comment that was aligned with its neighbours in its column when there is room.
+func replaceConfigValue(line, value string) string {
+	eq := strings.Index(line, "=")
+	head := strings.TrimRight(line[:eq], " \t")
+	rest := line[eq+1:]
+
+	updated := head + " = " + value
+	if c := commentStart(rest); c >= 0 {
+		gap := 1
+		if before := rest[:c]; len(before)-len(strings.TrimRight(before, " \t")) > 1 {
+			gap = max(eq+1+c-len(updated), 1)
+		}
+		updated += strings.Repeat(" ", gap) + rest[c:]
+	}
+	return updated
+}
+
+// commentStart returns the index of a # that is not inside a string, or -1.
+func commentStart(s string) int {
+	var quote byte
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case quote == 0 && (c == '"' || c == '\''):
+			quote = c
+		case quote == '"' && c == '\\':
+			i++
+		case quote != 0 && c == quote:
+			quote = 0
+		case quote == 0 && c == '#':
+			return i
+		}
+	}
+	return -1
+}
@@ -1,20 +1,28 @@
 package internal
 
 import (
+	"fmt"
 	"os"
 	"path/filepath"
 )
 
-func GetPIDFile() string {
+// GetRuntimeDir holds the pid file, lock and control socket. Without
+// XDG_RUNTIME_DIR it falls back to a per-user directory in /tmp.
+func GetRuntimeDir() string {
 	if xdg := os.Getenv("XDG_RUNTIME_DIR"); xdg != "" {
-		return filepath.Join(xdg, "yap.pid")
+		return xdg
 	}
-	return "/tmp/yap.pid"
+	return filepath.Join(os.TempDir(), fmt.Sprintf("yap-%d", os.Getuid()))
 }
 
-func GetStateFile() string {
-	if xdg := os.Getenv("XDG_RUNTIME_DIR"); xdg != "" {
-		return filepath.Join(xdg, "yap-state")
-	}
-	return "/tmp/yap-state"
+func GetPIDFile() string {
+	return filepath.Join(GetRuntimeDir(), "yap.pid")
+}
+
+func GetLockFile() string {
+	return filepath.Join(GetRuntimeDir(), "yap.lock")
+}
+
+func GetSocketFile() string {
+	return filepath.Join(GetRuntimeDir(), "yap.sock")
 }
@@ -0,0 +1,256 @@
+package internal
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	controlDialTimeout  = 2 * time.Second
+	controlReplyTimeout = 15 * time.Second
+	controlDrainTimeout = 2 * time.Second // how long Close waits for in-flight replies
+)
+
+// ErrNotRunning is returned by SendControl when no instance is listening on the control socket.
+var ErrNotRunning = errors.New("not running")
+
+// ControlRequest is a single command sent to a running instance over the control socket.
+type ControlRequest struct {
+	Cmd  string   `json:"cmd"`
+	Args []string `json:"args,omitempty"`
+}
+
+// ControlResponse is the acknowledged result of a ControlRequest.
+// State always reflects what the engine reported, never what the client asked for.
+type ControlResponse struct {
+	OK      bool        `json:"ok"`
+	State   string      `json:"state,omitempty"`
+	Error   string      `json:"error,omitempty"`
+	Message string      `json:"message,omitempty"`
+	Reason  string      `json:"reason,omitempty"` // machine-readable failure kind, e.g. "timeout"
+	Status  *StatusInfo `json:"status,omitempty"`
+}
+
+// StatusInfo describes a running instance, as answered to the "status" command.
+type StatusInfo struct {
+	Running        bool   `json:"running"`
+	State          string `json:"state"`
+	Model          string `json:"model,omitempty"`
+	Device         string `json:"device,omitempty"`
+	Language       string `json:"language,omitempty"`
+	Profile        string `json:"profile,omitempty"`
+	CaptureMode    string `json:"capture_mode,omitempty"`
+	PID            int    `json:"pid,omitempty"`
+	EnginePID      int    `json:"engine_pid,omitempty"`
+	Manager        string `json:"manager"` // "systemd", "daemon" or "foreground"
+	UptimeSeconds  int64  `json:"uptime_seconds"`
+	Transcriptions int    `json:"transcriptions"`
+	Restarts       int    `json:"restarts"`
+	StopTimeout    int    `json:"stop_timeout"` // seconds, as resolved for this instance
+
+	// Engine tuning in effect
+	Audio         *AudioSettings         `json:"audio,omitempty"`
+	VAD           *VADSettings           `json:"vad,omitempty"`
+	Transcription *TranscriptionSettings `json:"transcription,omitempty"`
+}
+
+// ControlEvent is pushed to clients that sent the "subscribe" command.
+type ControlEvent struct {
+	Type  string `json:"type"` // "state", "transcription", "loading" or "warning"
+	State string `json:"state,omitempty"`
+	Text  string `json:"text,omitempty"`
+	App   string `json:"app,omitempty"` // transcriptions: the [[app]] rule's window class
+}
+
+// ControlHandler answers a single request. It is called from its own goroutine per connection.
+type ControlHandler func(ControlRequest) ControlResponse
+
+// SubscribeFunc registers a subscriber. The channel is closed when the instance stops;
+// cancel unregisters it early (e.g. when the client disconnects).
+type SubscribeFunc func() (events <-chan ControlEvent, cancel func())
+
+// SendControl sends one request to the running instance and waits for its response.
+func SendControl(cmd string, args ...string) (*ControlResponse, error) {
+	return SendControlTimeout(controlReplyTimeout, cmd, args...)
+}
+
+// SendControlTimeout is SendControl for requests that may take longer than usual to answer.
+func SendControlTimeout(timeout time.Duration, cmd string, args ...string) (*ControlResponse, error) {
+	conn, err := dialControl()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	conn.SetDeadline(time.Now().Add(timeout))
+
+	if err := json.NewEncoder(conn).Encode(ControlRequest{Cmd: cmd, Args: args}); err != nil {
+		return nil, fmt.Errorf("failed to send %s: %w", cmd, err)
+	}
+
+	var resp ControlResponse
+	if err := json.NewDecoder(bufio.NewReader(conn)).Decode(&resp); err != nil {
+		return nil, fmt.Errorf("no response to %s: %w", cmd, err)
+	}
+
+	return &resp, nil
+}
+
+// Subscribe opens a long-lived connection that receives events until the instance stops.
+func Subscribe() (<-chan ControlEvent, error) {
+	conn, err := dialControl()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.NewEncoder(conn).Encode(ControlRequest{Cmd: "subscribe"}); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to subscribe: %w", err)
+	}
+
+	events := make(chan ControlEvent)
+	go func() {
+		defer conn.Close()
+		defer close(events)
+
+		decoder := json.NewDecoder(bufio.NewReader(conn))
+		for {
+			var event ControlEvent
+			if err := decoder.Decode(&event); err != nil {
+				return
+			}
+			events <- event
+		}
+	}()
+
+	return events, nil
+}
+
+func dialControl() (net.Conn, error) {
+	// Don't talk to a socket another user planted in /tmp
+	if err := ensureRuntimeDir(); err != nil {
+		return nil, err
+	}
+
+	conn, err := net.DialTimeout("unix", GetSocketFile(), controlDialTimeout)
+	if err != nil {
+		return nil, ErrNotRunning
+	}
+	return conn, nil
+}
+
+// ControlServer serves the control socket of a running instance.
+type ControlServer struct {
+	listener net.Listener
+	inflight sync.WaitGroup
+}
+
+// ListenControl binds the control socket and serves requests in the background.
+// A leftover socket from a dead instance is removed; a live one is an error.
+func ListenControl(handler ControlHandler, subscribe SubscribeFunc) (*ControlServer, error) {
+	path := GetSocketFile()
+
+	if conn, err := net.DialTimeout("unix", path, controlDialTimeout); err == nil {
+		conn.Close()
+		return nil, fmt.Errorf("control socket %s is already in use", path)
+	}
+	os.Remove(path)
+
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.Chmod(path, 0600); err != nil {
+		listener.Close()
+		return nil, err
+	}
+
+	server := &ControlServer{listener: listener}
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			server.inflight.Add(1)
+			go func() {
+				defer server.inflight.Done()
+				serveControlConn(conn, handler, subscribe)
+			}()
+		}
+	}()
+
+	return server, nil
+}
+
+// Close removes the socket and gives requests that are still being answered
+// (e.g. a blocking stop) a moment to deliver their response.
+func (s *ControlServer) Close() {
+	s.listener.Close()
+
+	drained := make(chan struct{})
+	go func() {
+		s.inflight.Wait()
+		close(drained)
+	}()
+
+	select {
+	case <-drained:
+	case <-time.After(controlDrainTimeout):
+	}
+}
+
+func serveControlConn(conn net.Conn, handler ControlHandler, subscribe SubscribeFunc) {
+	defer conn.Close()
+
+	reader := bufio.NewReader(conn)
+
+	var req ControlRequest
+	if err := json.NewDecoder(reader).Decode(&req); err != nil {
+		json.NewEncoder(conn).Encode(ControlResponse{Error: "malformed request"})
+		return
+	}
+
+	if req.Cmd == "subscribe" {
+		streamControlEvents(conn, reader, subscribe)
+		return
+	}
+
+	json.NewEncoder(conn).Encode(handler(req))
+}
+
+func streamControlEvents(conn net.Conn, reader io.Reader, subscribe SubscribeFunc) {
+	events, cancel := subscribe()
+	defer cancel()
+
+	// Subscribers never send anything else, so EOF means they went away
+	gone := make(chan struct{})
+	go func() {
+		io.Copy(io.Discard, reader)
+		close(gone)
+	}()
+
+	encoder := json.NewEncoder(conn)
+	for {
+		select {
+		case event, ok := <-events:
+			if !ok {
+				return
+			}
+			if err := encoder.Encode(event); err != nil {
+				return
+			}
+		case <-gone:
+			return
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package internal
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"syscall"
+)
+
+// daemonEnv marks re-executed daemon processes: "1" for the session leader, "2" for the daemon itself.
+const daemonEnv = "YAP_DAEMON"
+
+func GetLogFile() (string, error) {
+	stateDir, err := GetStateDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(stateDir, "yap.log"), nil
+}
+
+// IsDaemon reports whether this process is the detached daemon.
+func IsDaemon() bool {
+	return os.Getenv(daemonEnv) == "2"
+}
+
+// Daemonize detaches `yap <args>` from the terminal with a double fork:
+// the first child becomes a session leader, forks the daemon and exits, so the
+// daemon can never reacquire a controlling terminal. Output goes to the log file.
+func Daemonize(args []string) error {
+	logPath, err := GetLogFile()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(logPath), 0755); err != nil {
+		return err
+	}
+
+	// Fresh log per daemon session, like output.txt. A new file rather than a
+	// truncated one, so yap logs -f sees the switch however much is written.
+	if err := os.Remove(logPath); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	logFile, err := os.OpenFile(logPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	defer logFile.Close()
+
+	cmd, err := selfCommand(args, "1")
+	if err != nil {
+		return err
+	}
+	cmd.Stdout = logFile
+	cmd.Stderr = logFile
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("failed to detach: %w", err)
+	}
+	return nil
+}
+
+// ContinueDaemon runs in the session leader started by Daemonize: it forks the
+// daemon with the inherited log output and exits. It returns without doing
+// anything in every other process.
+func ContinueDaemon() {
+	if os.Getenv(daemonEnv) != "1" {
+		return
+	}
+
+	cmd, err := selfCommand(os.Args[1:], "2")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to start daemon: %v\n", err)
+		os.Exit(1)
+	}
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	if err := cmd.Start(); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to start daemon: %v\n", err)
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
+
+func selfCommand(args []string, stage string) (*exec.Cmd, error) {
+	exe, err := os.Executable()
+	if err != nil {
+		return nil, err
+	}
+
+	devNull, err := os.Open(os.DevNull)
+	if err != nil {
+		return nil, err
+	}
+
+	cmd := exec.Command(exe, args...)
+	cmd.Stdin = devNull
+	cmd.Env = append(os.Environ(), daemonEnv+"="+stage)
+	return cmd, nil
+}
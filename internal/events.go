@@ -0,0 +1,147 @@
+package internal
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+)
+
+// EngineProtocolVersion is the version of the engine's event stream (events.py).
+// Every event carries it as "v"; bump both sides on incompatible changes.
+const EngineProtocolVersion = 1
+
+// maxEventSize bounds a single event line (long transcriptions included).
+const maxEventSize = 1 << 20
+
+// EngineEvent is one decoded line of the engine's event stream.
+type EngineEvent interface {
+	engineEvent()
+}
+
+// ReadyEvent: the model is loaded and the engine accepts signals and commands.
+type ReadyEvent struct{}
+
+// StateEvent: the engine changed state (ready, listening, silence, processing, paused).
+type StateEvent struct {
+	State string `json:"state"`
+}
+
+// HeartbeatEvent: audio is still flowing.
+type HeartbeatEvent struct{}
+
+// EngineInfoEvent: the settings the engine actually runs with (e.g. after a GPU fallback).
+type EngineInfoEvent struct {
+	Model    string `json:"model"`
+	Device   string `json:"device"`
+	Language string `json:"language"` // "" when auto-detecting
+	Mode     string `json:"mode"`     // "fast" or "accurate"
+}
+
+// TranscriptionEvent: an utterance was transcribed and output.
+type TranscriptionEvent struct {
+	Text       string   `json:"text"`
+	Duration   float64  `json:"duration"`   // seconds of audio
+	Latency    float64  `json:"latency"`    // seconds spent transcribing
+	Confidence *float64 `json:"confidence"` // 0-1, nil if unknown
+	App        string   `json:"app"`        // focused window class when an [[app]] rule matched
+}
+
+// LoadingEvent: model load progress for the request with the given ID.
+type LoadingEvent struct {
+	ID       int      `json:"id"` // 0 for the model loaded at startup
+	Stage    string   `json:"stage"`
+	Progress *float64 `json:"progress"` // 0-1, nil if unknown
+}
+
+// ReplyEvent answers a command sent to the engine. ID 0 is the result of a
+// standalone run (--once).
+type ReplyEvent struct {
+	ID     int    `json:"id"`
+	OK     bool   `json:"ok"`
+	Error  string `json:"error"`
+	Reason string `json:"reason"` // machine-readable failure kind, e.g. "timeout"
+	Text   string `json:"text"`
+}
+
+// WarningEvent: something went wrong but the engine keeps running.
+type WarningEvent struct {
+	Message string `json:"message"`
+}
+
+// FatalEvent: why the engine is about to exit.
+type FatalEvent struct {
+	Message string `json:"message"`
+}
+
+func (ReadyEvent) engineEvent()         {}
+func (StateEvent) engineEvent()         {}
+func (HeartbeatEvent) engineEvent()     {}
+func (EngineInfoEvent) engineEvent()    {}
+func (TranscriptionEvent) engineEvent() {}
+func (LoadingEvent) engineEvent()       {}
+func (ReplyEvent) engineEvent()         {}
+func (WarningEvent) engineEvent()       {}
+func (FatalEvent) engineEvent()         {}
+
+// ParseEngineEvent decodes one line of the event stream.
+func ParseEngineEvent(line []byte) (EngineEvent, error) {
+	var header struct {
+		V    int    `json:"v"`
+		Type string `json:"type"`
+	}
+	if err := json.Unmarshal(line, &header); err != nil {
+		return nil, fmt.Errorf("malformed engine event: %w", err)
+	}
+	if header.V != EngineProtocolVersion {
+		return nil, fmt.Errorf("engine event protocol v%d, expected v%d", header.V, EngineProtocolVersion)
+	}
+
+	switch header.Type {
+	case "ready":
+		return ReadyEvent{}, nil
+	case "heartbeat":
+		return HeartbeatEvent{}, nil
+	case "state":
+		return decodeEvent[StateEvent](line)
+	case "engine":
+		return decodeEvent[EngineInfoEvent](line)
+	case "transcription":
+		return decodeEvent[TranscriptionEvent](line)
+	case "loading":
+		return decodeEvent[LoadingEvent](line)
+	case "reply":
+		return decodeEvent[ReplyEvent](line)
+	case "warning":
+		return decodeEvent[WarningEvent](line)
+	case "fatal":
+		return decodeEvent[FatalEvent](line)
+	default:
+		return nil, fmt.Errorf("unknown engine event: %s", header.Type)
+	}
+}
+
+func decodeEvent[T EngineEvent](line []byte) (EngineEvent, error) {
+	var event T
+	if err := json.Unmarshal(line, &event); err != nil {
+		return nil, fmt.Errorf("malformed engine event: %w", err)
+	}
+	return event, nil
+}
+
+// ReadEngineEvents calls handle for every event until the engine closes the
+// stream. Lines that fail to parse are reported on stderr and skipped.
+func ReadEngineEvents(r io.Reader, handle func(EngineEvent)) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), maxEventSize)
+
+	for scanner.Scan() {
+		event, err := ParseEngineEvent(scanner.Bytes())
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			continue
+		}
+		handle(event)
+	}
+}
@@ -0,0 +1,195 @@
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// ErrAlreadyRunning means another instance holds the instance lock.
+var ErrAlreadyRunning = errors.New("already running")
+
+// InstanceLock is held by the running instance for its whole lifetime. The
+// kernel drops it when the process dies, so it cannot go stale like a pid file.
+type InstanceLock struct {
+	file *os.File
+}
+
+// AcquireInstanceLock takes the instance lock and records this process in the pid file.
+func AcquireInstanceLock() (*InstanceLock, error) {
+	if err := ensureRuntimeDir(); err != nil {
+		return nil, err
+	}
+
+	path := GetLockFile()
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", path, err)
+	}
+
+	if err := syscall.Flock(int(file.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+		file.Close()
+		if errors.Is(err, syscall.EWOULDBLOCK) {
+			return nil, ErrAlreadyRunning
+		}
+		return nil, fmt.Errorf("failed to lock %s: %w", path, err)
+	}
+
+	if err := writePIDFile(os.Getpid()); err != nil {
+		fmt.Fprintf(os.Stderr, "warning: failed to write pid file: %v\n", err)
+	}
+
+	return &InstanceLock{file: file}, nil
+}
+
+// Release removes the pid file and drops the lock. The lock file itself stays:
+// deleting it would let two instances lock different files.
+func (l *InstanceLock) Release() {
+	os.Remove(GetPIDFile())
+	syscall.Flock(int(l.file.Fd()), syscall.LOCK_UN)
+	l.file.Close()
+}
+
+// InstanceRunning reports whether some process holds the instance lock.
+func InstanceRunning() bool {
+	file, err := os.Open(GetLockFile())
+	if err != nil {
+		return false
+	}
+	defer file.Close()
+
+	if err := syscall.Flock(int(file.Fd()), syscall.LOCK_SH|syscall.LOCK_NB); err != nil {
+		return errors.Is(err, syscall.EWOULDBLOCK)
+	}
+	syscall.Flock(int(file.Fd()), syscall.LOCK_UN)
+	return false
+}
+
+// WaitForExit waits up to timeout for the running instance to release its lock.
+func WaitForExit(timeout time.Duration) bool {
+	deadline := time.Now().Add(timeout)
+	for InstanceRunning() {
+		if time.Now().After(deadline) {
+			return false
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	return true
+}
+
+// GetPID returns the pid of the running instance. The pid file is only trusted
+// while the lock is held and the process still is the one that wrote it, so a
+// recycled pid is never reported.
+func GetPID() (int, error) {
+	if !InstanceRunning() {
+		return 0, ErrNotRunning
+	}
+
+	data, err := os.ReadFile(GetPIDFile())
+	if err != nil {
+		return 0, err
+	}
+
+	fields := strings.Fields(string(data))
+	if len(fields) == 0 {
+		return 0, fmt.Errorf("empty pid file")
+	}
+	pid, err := strconv.Atoi(fields[0])
+	if err != nil {
+		return 0, err
+	}
+
+	if len(fields) > 1 {
+		recorded, err := strconv.ParseUint(fields[1], 10, 64)
+		if err != nil {
+			return 0, err
+		}
+		if started, err := processStartTime(pid); err != nil || started != recorded {
+			return 0, fmt.Errorf("pid %d is no longer the yap instance", pid)
+		}
+	} else if !isYapProcess(pid) {
+		// Written by an older version without a start time
+		return 0, fmt.Errorf("pid %d is no longer the yap instance", pid)
+	}
+
+	return pid, nil
+}
+
+func writePIDFile(pid int) error {
+	started, err := processStartTime(pid)
+	if err != nil {
+		return err
+	}
+
+	// Write then rename, so readers never see a half-written file
+	path := GetPIDFile()
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, []byte(fmt.Sprintf("%d %d\n", pid, started)), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// processStartTime returns when pid started, in clock ticks since boot
+// (field 22 of /proc/<pid>/stat). A recycled pid has a different start time.
+func processStartTime(pid int) (uint64, error) {
+	data, err := os.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+	if err != nil {
+		return 0, err
+	}
+
+	// The command name (field 2) may contain spaces; fields resume after its ')'
+	stat := string(data)
+	end := strings.LastIndexByte(stat, ')')
+	if end < 0 {
+		return 0, fmt.Errorf("malformed /proc/%d/stat", pid)
+	}
+	fields := strings.Fields(stat[end+1:])
+	if len(fields) < 20 {
+		return 0, fmt.Errorf("malformed /proc/%d/stat", pid)
+	}
+	return strconv.ParseUint(fields[19], 10, 64)
+}
+
+// isYapProcess checks /proc/<pid>/cmdline for a `yap start` of this binary.
+func isYapProcess(pid int) bool {
+	data, err := os.ReadFile(fmt.Sprintf("/proc/%d/cmdline", pid))
+	if err != nil {
+		return false
+	}
+	argv := strings.Split(strings.TrimRight(string(data), "\x00"), "\x00")
+
+	exe, err := os.Executable()
+	if err != nil || len(argv) < 2 {
+		return false
+	}
+	return filepath.Base(argv[0]) == filepath.Base(exe) && argv[1] == "start"
+}
+
+// ensureRuntimeDir creates the /tmp fallback runtime directory and refuses to
+// use it if another user got there first.
+func ensureRuntimeDir() error {
+	if os.Getenv("XDG_RUNTIME_DIR") != "" {
+		return nil
+	}
+
+	dir := GetRuntimeDir()
+	if err := os.Mkdir(dir, 0700); err != nil && !os.IsExist(err) {
+		return fmt.Errorf("failed to create %s: %w", dir, err)
+	}
+
+	info, err := os.Lstat(dir)
+	if err != nil {
+		return err
+	}
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !info.IsDir() || !ok || int(stat.Uid) != os.Getuid() || info.Mode().Perm()&0077 != 0 {
+		return fmt.Errorf("refusing to use %s: not a private directory owned by you", dir)
+	}
+	return nil
+}
@@ -0,0 +1,363 @@
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+)
+
+// ConfigVersion is the config_version this build writes. Files without one
+// predate versioning and count as version 0.
+const ConfigVersion = 4
+
+// migration upgrades config.toml lines from version-1 to version.
+type migration struct {
+	version int
+	summary string
+	apply   func(lines []string) []string
+}
+
+// migrations run in order; append new ones, never edit released ones.
+var migrations = []migration{
+	{
+		version: 1,
+		summary: `add settings missing from early config files (with their defaults), spell device "cuda" as "gpu"`,
+		apply: func(lines []string) []string {
+			lines = addMissingSettings(lines,
+				`notifications = "urgent" # Examples: "start,pause,stop" | "urgent" | "false"`,
+				`model = "tiny" # tiny/base/small/medium/large`,
+				`device = "cpu" # cpu/gpu`,
+				`language = "en" # "auto"/"" for auto-detect`,
+				`fast_mode = false`,
+				`enable_typing = true`,
+				`output_file = false # ~/.config/yappers-of-linux/output.txt`,
+				`timeout = 0          # seconds of no output before auto-pause (0 = disabled)`,
+				`idle_stop = 0        # minutes paused before the engine is stopped to free memory (0 = disabled)`,
+				`tcp_port = 0         # TCP push server port (0 = disabled)`,
+				`stop_timeout = 10    # seconds to let the engine finish before it is killed on stop`,
+				`flush_on_stop = true # transcribe the utterance being recorded when stopping`,
+				`capture_mode = "vad" # "vad" records when you speak, "ptt" between yap hold and yap release`,
+			)
+			return renameValue(lines, "device", "cuda", "gpu")
+		},
+	},
+	{
+		version: 2,
+		summary: "add the [audio], [vad] and [transcription] tuning tables (with their defaults)",
+		apply: func(lines []string) []string {
+			return addMissingTables(lines, "audio", "vad", "transcription")
+		},
+	},
+	{
+		version: 3,
+		summary: "add the [sounds] table for sound cues (off by default)",
+		apply: func(lines []string) []string {
+			return addMissingTables(lines, "sounds")
+		},
+	},
+	{
+		version: 4,
+		summary: `add the typing_backend setting ("auto")`,
+		apply: func(lines []string) []string {
+			return addMissingSettings(lines, `typing_backend = "auto" # or wtype/xdotool/ydotool/dotool/uinput, comma-separated to try several in order`)
+		},
+	},
+}
+
+// ConfigMigration is a pending upgrade of config.toml.
+type ConfigMigration struct {
+	Path     string
+	From     int
+	Summary  []string
+	Old, New []string // file lines before and after
+}
+
+// PlanConfigMigration returns the upgrade config.toml needs, or nil when it is
+// missing, current, from a newer yap, or too broken to parse.
+func PlanConfigMigration() (*ConfigMigration, error) {
+	path, err := GetConfigFile()
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var versioned struct {
+		ConfigVersion int `toml:"config_version"`
+	}
+	if _, err := toml.Decode(string(data), &versioned); err != nil {
+		// yap config check reports it; rewriting a file we cannot read would make it worse
+		return nil, nil
+	}
+	if versioned.ConfigVersion >= ConfigVersion {
+		return nil, nil
+	}
+
+	old := strings.Split(string(data), "\n")
+	plan := &ConfigMigration{Path: path, From: versioned.ConfigVersion, Old: old}
+	lines := append([]string(nil), old...)
+	for _, m := range migrations {
+		if m.version > versioned.ConfigVersion {
+			lines = m.apply(lines)
+			plan.Summary = append(plan.Summary, fmt.Sprintf("v%d: %s", m.version, m.summary))
+		}
+	}
+	plan.New = setConfigVersion(lines, ConfigVersion)
+
+	return plan, nil
+}
+
+// Apply backs up config.toml next to it and writes the migrated file. It
+// returns the backup's path.
+func (m *ConfigMigration) Apply() (string, error) {
+	backup := fmt.Sprintf("%s.v%d.bak", m.Path, m.From)
+	if err := os.WriteFile(backup, []byte(strings.Join(m.Old, "\n")), 0600); err != nil {
+		return "", fmt.Errorf("failed to back up config: %w", err)
+	}
+	if err := writeConfigFile(m.Path, []byte(strings.Join(m.New, "\n"))); err != nil {
+		return "", err
+	}
+	return backup, nil
+}
+
+// Diff renders the migration as a unified diff.
+func (m *ConfigMigration) Diff() string {
+	return unifiedDiff(m.Old, m.New, fmt.Sprintf("config.toml (v%d)", m.From), fmt.Sprintf("config.toml (v%d)", ConfigVersion))
+}
+
+// MigrateConfig upgrades an outdated config.toml in place, keeping a backup.
+func MigrateConfig() error {
+	plan, err := PlanConfigMigration()
+	if err != nil || plan == nil {
+		return err
+	}
+	backup, err := plan.Apply()
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "updated config.toml to version %d (previous file saved as %s)\n", ConfigVersion, backup)
+	return nil
+}
+
+// setConfigVersion writes config_version, as the first setting if it is new.
+func setConfigVersion(lines []string, version int) []string {
+	entry := fmt.Sprintf("config_version = %d", version)
+	if i := findConfigKey(lines, "", "config_version"); i >= 0 {
+		lines[i] = replaceConfigValue(lines[i], fmt.Sprint(version))
+		return lines
+	}
+
+	at := len(lines)
+	for i, line := range lines {
+		if header, key := configLine(line); header != "" || key != "" {
+			at = i
+			break
+		}
+	}
+	return append(lines[:at:at], append([]string{entry + " # used by yap to upgrade this file, do not edit"}, lines[at:]...)...)
+}
+
+// addMissingSettings adds the given top-level settings, each a config line as
+// released, that the file lacks. They carry the built-in default of their
+// time, so the setting shows up without changing behavior.
+func addMissingSettings(lines []string, settings ...string) []string {
+	for _, setting := range settings {
+		if _, key := configLine(setting); findConfigKey(lines, "", key) < 0 {
+			lines = insertConfigKey(lines, "", setting)
+		}
+	}
+	return lines
+}
+
+// addMissingTables copies the keys of the given tables from the example config
+// that the file lacks, with the built-in defaults. Missing tables are added
+// whole, with the example's comments, ahead of the file's first table.
+func addMissingTables(lines []string, tables ...string) []string {
+	defaults := builtinDefaults()
+	example := strings.Split(string(defaultConfig), "\n")
+
+	var block []string // the missing tables, in example order
+	current, missing := "", false
+	for i, line := range example {
+		header, key := configLine(line)
+		if header != "" {
+			current = header
+			missing = IsOneOf(header, tables) && !tableExists(lines, header)
+			if missing {
+				// Keep the comment block right above the header
+				start := i
+				for start > 0 && strings.HasPrefix(strings.TrimSpace(example[start-1]), "#") {
+					start--
+				}
+				if len(block) > 0 {
+					block = append(block, "")
+				}
+				block = append(block, example[start:i+1]...)
+			}
+			continue
+		}
+		if key == "" || !IsOneOf(current, tables) {
+			continue
+		}
+		value, err := defaults.Value(current + "." + key)
+		if err != nil {
+			continue
+		}
+		entry := replaceConfigValue(line, FormatConfigValue(value))
+		if missing {
+			block = append(block, entry)
+		} else if findConfigKey(lines, current, key) < 0 {
+			lines = insertConfigKey(lines, current, entry)
+		}
+	}
+	if len(block) == 0 {
+		return lines
+	}
+
+	at := tableInsertPoint(lines)
+	block = append(block, "")
+	if at > 0 && strings.TrimSpace(lines[at-1]) != "" {
+		block = append([]string{""}, block...)
+	}
+	return slices.Insert(lines, at, block...)
+}
+
+// tableInsertPoint is the line before the first table header and the comments
+// directly above it, or the end of the file (before its final newline).
+func tableInsertPoint(lines []string) int {
+	for i, line := range lines {
+		if header, _ := configLine(line); header != "" {
+			for i > 0 && strings.HasPrefix(strings.TrimSpace(lines[i-1]), "#") {
+				i--
+			}
+			return i
+		}
+	}
+	at := len(lines)
+	if at > 0 && lines[at-1] == "" {
+		at--
+	}
+	return at
+}
+
+func tableExists(lines []string, table string) bool {
+	for _, line := range lines {
+		if header, _ := configLine(line); header == table {
+			return true
+		}
+	}
+	return false
+}
+
+// renameValue replaces a string value of key, in every table.
+func renameValue(lines []string, key, from, to string) []string {
+	for i, line := range lines {
+		if _, k := configLine(line); k != key {
+			continue
+		}
+		rest := line[strings.Index(line, "=")+1:]
+		if c := commentStart(rest); c >= 0 {
+			rest = rest[:c]
+		}
+		value := strings.Trim(strings.TrimSpace(rest), `"'`)
+		if value == from {
+			lines[i] = replaceConfigValue(line, tomlString(to))
+		}
+	}
+	return lines
+}
+
+// unifiedDiff compares two small files line by line, with two lines of context.
+func unifiedDiff(a, b []string, nameA, nameB string) string {
+	// Longest common subsequence, from the end
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	type op struct {
+		kind byte // ' ', '-' or '+'
+		line string
+		ai   int // line numbers before and after, 0-based
+		bi   int
+	}
+	var ops []op
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			ops = append(ops, op{' ', a[i], i, j})
+			i++
+			j++
+		case i < len(a) && (j == len(b) || lcs[i+1][j] >= lcs[i][j+1]):
+			ops = append(ops, op{'-', a[i], i, j})
+			i++
+		default:
+			ops = append(ops, op{'+', b[j], i, j})
+			j++
+		}
+	}
+
+	const context = 2
+	var out strings.Builder
+	fmt.Fprintf(&out, "--- %s\n+++ %s\n", nameA, nameB)
+	for k := 0; k < len(ops); {
+		if ops[k].kind == ' ' {
+			k++
+			continue
+		}
+		// Grow the hunk while changes are closer than two contexts apart
+		start := max(k-context, 0)
+		end := k
+		for end < len(ops) {
+			if ops[end].kind != ' ' {
+				end++
+				continue
+			}
+			next := end
+			for next < len(ops) && ops[next].kind == ' ' {
+				next++
+			}
+			if next == len(ops) || next-end > 2*context {
+				end = min(end+context, len(ops))
+				break
+			}
+			end = next
+		}
+
+		countA, countB := 0, 0
+		for _, o := range ops[start:end] {
+			if o.kind != '+' {
+				countA++
+			}
+			if o.kind != '-' {
+				countB++
+			}
+		}
+		fmt.Fprintf(&out, "@@ -%d,%d +%d,%d @@\n", ops[start].ai+1, countA, ops[start].bi+1, countB)
+		for _, o := range ops[start:end] {
+			fmt.Fprintf(&out, "%c%s\n", o.kind, o.line)
+		}
+		k = end
+	}
+	return out.String()
+}
@@ -0,0 +1,251 @@
+package internal
+
+import (
+	"context"
+	"os/exec"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+)
+
+const (
+	notificationsName = "org.freedesktop.Notifications"
+	notificationsPath = "/org/freedesktop/Notifications"
+	notificationIcon  = "audio-input-microphone"
+
+	notificationTimeout = 2 * time.Second // per call to the server
+	notificationQueue   = 16              // notifications waiting to be sent
+)
+
+// notificationUrgency is the spec's urgency byte for each of NotificationUrgencies.
+var notificationUrgency = map[string]byte{"low": 0, "normal": 1, "critical": 2}
+
+// notificationActions are the buttons for the state an event leaves yap in,
+// as action key/label pairs. The keys are control commands, run when clicked.
+var notificationActions = map[string][]string{
+	"start":       {"pause", "Pause", "stop", "Stop"},
+	"transcribed": {"pause", "Pause", "stop", "Stop"},
+	"pause":       {"resume", "Resume", "stop", "Stop"},
+	"timeout":     {"resume", "Resume", "stop", "Stop"},
+}
+
+// Notifier shows notifications in one bubble that each event updates, talking
+// to the notification server over the session bus. Without a bus it falls
+// back to a notify-send bubble per event.
+//
+// Notifications are sent from a goroutine of their own, with a timeout on
+// each call, so a hung server never holds up the caller.
+type Notifier struct {
+	onAction func(action string)
+	dial     func() (*dbus.Conn, error) // connects to the bus
+	queue    chan notification
+	done     chan struct{}
+
+	// Only the sending goroutine uses these
+	conn       *dbus.Conn
+	hasActions bool // the server draws buttons, asked once per connection
+
+	mu      sync.Mutex // guards the fields below, shared with watch
+	id      uint32     // bubble to replace, 0 for a new one
+	actions []string   // the bubble's buttons, as key/label pairs
+	closed  bool
+}
+
+type notification struct {
+	message, event, urgency string
+	percent                 int
+}
+
+// NewNotifier returns a Notifier that calls onAction with the control command
+// of a clicked button. The bus is connected on first use.
+func NewNotifier(onAction func(action string)) *Notifier {
+	return newNotifier(onAction, func() (*dbus.Conn, error) { return dbus.ConnectSessionBus() })
+}
+
+// newNotifier is NewNotifier on the bus dial connects to.
+func newNotifier(onAction func(action string), dial func() (*dbus.Conn, error)) *Notifier {
+	n := &Notifier{
+		onAction: onAction,
+		dial:     dial,
+		queue:    make(chan notification, notificationQueue),
+		done:     make(chan struct{}),
+	}
+	go n.run()
+	return n
+}
+
+// Notify shows message if event is enabled in cfg's notifications.
+func (n *Notifier) Notify(message string, event string, cfg *Config) {
+	n.show(message, event, -1, cfg)
+}
+
+// Progress is Notify with a progress bar at percent (0-100), where the
+// notification server draws one.
+func (n *Notifier) Progress(message string, event string, percent int, cfg *Config) {
+	n.show(message, event, percent, cfg)
+}
+
+func (n *Notifier) show(message, event string, percent int, cfg *Config) {
+	notifCfg := ParseNotifications(cfg.Notifications)
+	if !notifCfg.ShouldNotify(event) {
+		return
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.closed {
+		return
+	}
+	select {
+	case n.queue <- notification{message, event, notifCfg.UrgencyOf(event), percent}:
+	default:
+		// The server is not keeping up; later events say where things stand
+	}
+}
+
+// Close sends what is still queued, removes a bubble whose buttons would no
+// longer do anything and disconnects from the bus. It gives up waiting on a
+// server that does not answer.
+func (n *Notifier) Close() {
+	n.mu.Lock()
+	if n.closed {
+		n.mu.Unlock()
+		return
+	}
+	n.closed = true
+	close(n.queue)
+	n.mu.Unlock()
+
+	select {
+	case <-n.done:
+	case <-time.After(2 * notificationTimeout):
+	}
+}
+
+// run sends the queued notifications until Close.
+func (n *Notifier) run() {
+	defer close(n.done)
+	for note := range n.queue {
+		if n.conn == nil {
+			// Retried per event: a service can start before the session bus
+			n.connect()
+		}
+		if n.conn == nil || !n.send(note) {
+			notifySend(note.message, note.urgency)
+		}
+	}
+
+	if n.conn == nil {
+		return
+	}
+	n.mu.Lock()
+	id, offered := n.id, len(n.actions) > 0
+	n.mu.Unlock()
+	if offered {
+		n.call("CloseNotification", id)
+	}
+	n.disconnect()
+}
+
+func (n *Notifier) connect() {
+	conn, err := n.dial()
+	if err != nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), notificationTimeout)
+	defer cancel()
+	err = conn.AddMatchSignalContext(ctx,
+		dbus.WithMatchObjectPath(notificationsPath),
+		dbus.WithMatchInterface(notificationsName),
+	)
+	if err != nil {
+		conn.Close()
+		return
+	}
+
+	signals := make(chan *dbus.Signal, 8)
+	conn.Signal(signals)
+	go n.watch(signals)
+	n.conn = conn
+
+	var caps []string
+	n.hasActions = n.call("GetCapabilities").Store(&caps) == nil && slices.Contains(caps, "actions")
+}
+
+// disconnect drops the connection, and with it the bubble it showed.
+func (n *Notifier) disconnect() {
+	n.conn.Close()
+	n.conn = nil
+	n.mu.Lock()
+	n.id, n.actions = 0, nil
+	n.mu.Unlock()
+}
+
+// call calls a method of the notification server, giving up after
+// notificationTimeout.
+func (n *Notifier) call(method string, args ...any) *dbus.Call {
+	ctx, cancel := context.WithTimeout(context.Background(), notificationTimeout)
+	defer cancel()
+	return n.conn.Object(notificationsName, notificationsPath).CallWithContext(ctx, notificationsName+"."+method, 0, args...)
+}
+
+// send shows or updates the bubble, reporting whether a server took it. A
+// server that fails to is dropped, to be connected afresh next time.
+func (n *Notifier) send(note notification) bool {
+	actions := []string{}
+	if n.hasActions {
+		actions = append(actions, notificationActions[note.event]...)
+	}
+	hints := map[string]dbus.Variant{"urgency": dbus.MakeVariant(notificationUrgency[note.urgency])}
+	if note.percent >= 0 {
+		hints["value"] = dbus.MakeVariant(int32(note.percent))
+	}
+
+	n.mu.Lock()
+	replaces := n.id
+	n.mu.Unlock()
+
+	var id uint32
+	if n.call("Notify", "Yap", replaces, notificationIcon, "Yap", note.message, actions, hints, int32(-1)).Store(&id) != nil {
+		n.disconnect()
+		return false
+	}
+	n.mu.Lock()
+	n.id, n.actions = id, actions
+	n.mu.Unlock()
+	return true
+}
+
+// watch handles the server's signals until the connection is closed.
+func (n *Notifier) watch(signals <-chan *dbus.Signal) {
+	for signal := range signals {
+		if len(signal.Body) < 2 {
+			continue
+		}
+		id, _ := signal.Body[0].(uint32)
+
+		action, _ := signal.Body[1].(string)
+
+		n.mu.Lock()
+		ours := id != 0 && id == n.id
+		offered := slices.Contains(n.actions, action)
+		if ours && signal.Name == notificationsName+".NotificationClosed" {
+			// Dismissed: the next event opens a new bubble
+			n.id, n.actions = 0, nil
+		}
+		n.mu.Unlock()
+
+		if ours && offered && signal.Name == notificationsName+".ActionInvoked" {
+			go n.onAction(action)
+		}
+	}
+}
+
+func notifySend(message, urgency string) {
+	cmd := exec.Command("notify-send", "-i", notificationIcon, "-u", urgency, "Yap", message)
+	if err := cmd.Start(); err == nil {
+		go cmd.Wait()
+	}
+}
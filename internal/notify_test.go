@@ -0,0 +1,197 @@
+package internal
+
+import (
+	"bufio"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+)
+
+// fakeNotifications is a notification server that records what it is asked.
+type fakeNotifications struct {
+	hang chan struct{} // Notify blocks until it is closed, when set
+
+	mu       sync.Mutex
+	next     uint32
+	replaces []uint32
+	closed   []uint32
+}
+
+func (f *fakeNotifications) GetCapabilities() ([]string, *dbus.Error) {
+	return []string{"actions", "body"}, nil
+}
+
+func (f *fakeNotifications) Notify(app string, replaces uint32, icon, summary, body string, actions []string, hints map[string]dbus.Variant, timeout int32) (uint32, *dbus.Error) {
+	if f.hang != nil {
+		<-f.hang
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.replaces = append(f.replaces, replaces)
+	if replaces != 0 {
+		return replaces, nil
+	}
+	f.next++
+	return f.next, nil
+}
+
+func (f *fakeNotifications) CloseNotification(id uint32) *dbus.Error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.closed = append(f.closed, id)
+	return nil
+}
+
+func (f *fakeNotifications) calls() []uint32 {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]uint32(nil), f.replaces...)
+}
+
+// privateBus starts a dbus-daemon of its own with fake serving the
+// notifications interface, and returns how to connect to it.
+func privateBus(t *testing.T, fake *fakeNotifications) (dial func() (*dbus.Conn, error), server *dbus.Conn) {
+	t.Helper()
+	if _, err := exec.LookPath("dbus-daemon"); err != nil {
+		t.Skip("dbus-daemon not found")
+	}
+
+	daemon := exec.Command("dbus-daemon", "--session", "--nofork", "--print-address=1")
+	stdout, err := daemon.StdoutPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := daemon.Start(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		daemon.Process.Kill()
+		daemon.Wait()
+	})
+	address, err := bufio.NewReader(stdout).ReadString('\n')
+	if err != nil {
+		t.Fatalf("dbus-daemon gave no address: %v", err)
+	}
+	address = strings.TrimSpace(address)
+
+	server, err = dbus.Connect(address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { server.Close() })
+	if err := server.Export(fake, notificationsPath, notificationsName); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := server.RequestName(notificationsName, dbus.NameFlagDoNotQueue); err != nil {
+		t.Fatal(err)
+	}
+
+	return func() (*dbus.Conn, error) { return dbus.Connect(address) }, server
+}
+
+// waitFor polls until ok holds, failing the test after a few seconds.
+func waitFor(t *testing.T, what string, ok func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(3 * notificationTimeout)
+	for !ok() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestNotifierReplacesBubble(t *testing.T) {
+	fake := &fakeNotifications{}
+	dial, _ := privateBus(t, fake)
+	cfg := &Config{Notifications: "start,pause"}
+
+	n := newNotifier(func(string) {}, dial)
+	n.Notify("Yapping started", "start", cfg)
+	n.Notify("Yapping paused", "pause", cfg)
+	n.Close()
+
+	calls := fake.calls()
+	if len(calls) != 2 || calls[0] != 0 || calls[1] != 1 {
+		t.Fatalf("replaces_id of each Notify = %v, want [0 1]", calls)
+	}
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+	if len(fake.closed) != 1 || fake.closed[0] != 1 {
+		t.Errorf("closed %v on Close, want [1]", fake.closed)
+	}
+}
+
+func TestNotifierActionInvoked(t *testing.T) {
+	fake := &fakeNotifications{}
+	dial, server := privateBus(t, fake)
+	cfg := &Config{Notifications: "pause"}
+
+	actions := make(chan string, 1)
+	n := newNotifier(func(action string) { actions <- action }, dial)
+	defer n.Close()
+	n.Notify("Yapping paused", "pause", cfg)
+	waitFor(t, "the notification", func() bool { return len(fake.calls()) == 1 })
+
+	// Another bubble's button, then a button this bubble does not have
+	server.Emit(notificationsPath, notificationsName+".ActionInvoked", uint32(7), "resume")
+	server.Emit(notificationsPath, notificationsName+".ActionInvoked", uint32(1), "pause")
+	server.Emit(notificationsPath, notificationsName+".ActionInvoked", uint32(1), "resume")
+
+	select {
+	case action := <-actions:
+		if action != "resume" {
+			t.Fatalf("onAction(%q), want resume", action)
+		}
+	case <-time.After(notificationTimeout):
+		t.Fatal("onAction not called")
+	}
+	select {
+	case action := <-actions:
+		t.Fatalf("unexpected onAction(%q)", action)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestNotifierTimeout(t *testing.T) {
+	fake := &fakeNotifications{hang: make(chan struct{})}
+	dial, _ := privateBus(t, fake)
+	defer close(fake.hang)
+	cfg := &Config{Notifications: "start"}
+
+	// The fallback records its calls instead of showing anything
+	bin := t.TempDir()
+	log := filepath.Join(bin, "notify-send.log")
+	script := "#!/bin/sh\necho \"$*\" >> " + log + "\n"
+	if err := os.WriteFile(filepath.Join(bin, "notify-send"), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", bin)
+
+	n := newNotifier(func(string) {}, dial)
+	start := time.Now()
+	n.Notify("Yapping started", "start", cfg)
+	if elapsed := time.Since(start); elapsed > 100*time.Millisecond {
+		t.Errorf("Notify blocked for %s on a hung server", elapsed)
+	}
+
+	waitFor(t, "the notify-send fallback", func() bool {
+		data, _ := os.ReadFile(log)
+		return strings.Contains(string(data), "Yapping started")
+	})
+	if elapsed := time.Since(start); elapsed < notificationTimeout {
+		t.Errorf("fell back after %s, before the %s timeout", elapsed, notificationTimeout)
+	}
+
+	start = time.Now()
+	n.Close()
+	if elapsed := time.Since(start); elapsed > notificationTimeout {
+		t.Errorf("Close took %s", elapsed)
+	}
+}
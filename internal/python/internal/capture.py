@@ -19,22 +19,32 @@ with warnings.catch_warnings():
     warnings.simplefilter("ignore", category=UserWarning)
     import webrtcvad
 
+from . import events
 from .config import AudioConfig, VADConfig, ThreadConfig
 
 
 class AudioCapture:
     """Manages audio stream and buffering."""
 
-    def __init__(self):
+    def __init__(self, silence_duration=AudioConfig.SILENCE_DURATION_SEC,
+                 pre_buffer=AudioConfig.PRE_BUFFER_DURATION_SEC,
+                 vad_aggressiveness=VADConfig.AGGRESSIVENESS):
+        """
+        Args:
+            silence_duration: Seconds of silence that end an utterance
+            pre_buffer: Seconds of audio kept from before speech was detected
+            vad_aggressiveness: webrtcvad mode, 0 (lenient) to 3 (strict)
+        """
         self.audio_queue = queue.Queue()
         self._running = False
         self._reader_thread = None
 
-        self.vad = webrtcvad.Vad(VADConfig.AGGRESSIVENESS)
+        self.silence_duration = silence_duration
+        self.vad_aggressiveness = vad_aggressiveness
+        self.vad = webrtcvad.Vad(vad_aggressiveness)
 
-        self.pre_buffer = collections.deque(
-            maxlen=int(AudioConfig.PRE_BUFFER_DURATION_SEC * AudioConfig.RATE / AudioConfig.CHUNK_SIZE)
-        )
+        self.pre_buffer = collections.deque()
+        self.set_pre_buffer(pre_buffer)
         self.recording_buffer = []
         self.is_recording = False
         self.silence_chunks = 0
@@ -107,8 +117,10 @@ class AudioCapture:
                     break
                 chunk = self.stream.read(AudioConfig.CHUNK_SIZE, exception_on_overflow=False)
                 self.audio_queue.put(chunk)
-            except (OSError, IOError, AttributeError):
-                # Stream closed or became None during pause
+            except (OSError, IOError, AttributeError) as e:
+                # Stream closed or became None during pause; otherwise the device went away
+                if self._running and self.stream is not None:
+                    events.warning(f"microphone lost: {e}")
                 break
 
     def get_chunk(self, timeout=None):
@@ -132,14 +144,27 @@ class AudioCapture:
         """Check if audio chunk contains speech using VAD."""
         return self.vad.is_speech(chunk, AudioConfig.RATE)
 
+    def set_vad_aggressiveness(self, level):
+        """Change how readily the VAD treats sound as speech (0-3)."""
+        self.vad.set_mode(level)
+        self.vad_aggressiveness = level
+
+    def set_pre_buffer(self, seconds):
+        """Resize the pre-buffer, keeping the most recent audio."""
+        self.pre_buffer_duration = seconds
+        self.pre_buffer = collections.deque(
+            self.pre_buffer,
+            maxlen=int(seconds * AudioConfig.RATE / AudioConfig.CHUNK_SIZE)
+        )
+
     def add_to_pre_buffer(self, chunk):
         """Add chunk to circular pre-buffer."""
         self.pre_buffer.append(chunk)
 
-    def start_recording(self):
-        """Start recording (copies pre-buffer to recording buffer)."""
+    def start_recording(self, with_pre_buffer=True):
+        """Start recording (copies pre-buffer to recording buffer unless disabled)."""
         self.is_recording = True
-        self.recording_buffer = list(self.pre_buffer)
+        self.recording_buffer = list(self.pre_buffer) if with_pre_buffer else []
         self.silence_chunks = 0
 
     def add_to_recording(self, chunk):
@@ -161,7 +186,7 @@ class AudioCapture:
 
     def should_stop_recording(self):
         """Check if silence duration exceeds threshold."""
-        return self.get_silence_duration() >= AudioConfig.SILENCE_DURATION_SEC
+        return self.get_silence_duration() >= self.silence_duration
 
     def get_recording(self):
         """Get current recording buffer."""
@@ -1,7 +1,9 @@
 """
 Configuration constants for voice typing engine.
 
-All tunable parameters are centralized here for easy adjustment.
+All tunable parameters are centralized here for easy adjustment. The ones
+marked as config.toml defaults are overridden by the [audio], [vad] and
+[transcription] tables, which yap passes as command-line options.
 """
 
 
@@ -11,23 +13,24 @@ class AudioConfig:
     RATE = 16000
     CHUNK_DURATION_MS = 30
     CHUNK_SIZE = int(RATE * CHUNK_DURATION_MS / 1000)
-    PRE_BUFFER_DURATION_SEC = 1.5
+    PRE_BUFFER_DURATION_SEC = 1.5  # config.toml default: audio.pre_buffer
     BUFFER_DURATION_SEC = 4.0
-    SILENCE_DURATION_SEC = 0.8
+    SILENCE_DURATION_SEC = 0.8  # config.toml default: audio.silence_duration
+    PTT_MAX_HOLD_SEC = 120.0  # auto-release if `yap release` never arrives
 
 
 class VADConfig:
     """Voice Activity Detection parameters."""
 
-    AGGRESSIVENESS = 3
+    AGGRESSIVENESS = 3  # config.toml default: vad.aggressiveness
 
 
 class TranscriptionConfig:
     """Whisper transcription parameters."""
 
-    MIN_AUDIO_DURATION_SEC = 0.7
-    MIN_CONFIDENCE = -0.7
-    BEAM_SIZE = 5
+    MIN_AUDIO_DURATION_SEC = 0.7  # config.toml default: transcription.min_audio_duration
+    MIN_CONFIDENCE = -0.7  # config.toml default: transcription.min_confidence
+    BEAM_SIZE = 5  # config.toml default: transcription.beam_size
     BEST_OF = 3
     TEMPERATURE = 0.0
     VAD_MIN_SILENCE_MS = 400
@@ -36,6 +39,14 @@ class TranscriptionConfig:
     COMPUTE_TYPE_GPU = "float16"
 
 
+class FocusConfig:
+    """Focused window detection for [[app]] rules."""
+
+    COMMAND_TIMEOUT_SEC = 1.0
+    KWIN_TIMEOUT_SEC = 0.5  # wait for the KWin script's output in the journal
+    KWIN_POLL_SEC = 0.05
+
+
 class DisplayConfig:
     """UI and display parameters."""
 
@@ -54,3 +65,4 @@ class ThreadConfig:
 
     AUDIO_QUEUE_TIMEOUT_SEC = 0.1
     CLEANUP_SLEEP_SEC = 0.1
+    HEARTBEAT_INTERVAL_SEC = 5.0
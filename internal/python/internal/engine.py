@@ -7,17 +7,24 @@ Coordinates all components:
 - Text output
 - TCP server (optional)
 - State machine (ready → recording → processing → ready)
-- Signal handlers (pause/resume)
+- Signal handlers (pause/resume/graceful stop)
+- Supervisor commands (runtime reconfiguration, push-to-talk, one-shot dictation)
+- Per-application rules for the focused window (see focus.py)
+- Event stream to the supervisor (see events.py)
 """
 
+import json
+import os
 import signal
-import subprocess
 import threading
 import queue
 import time
 
+from . import events
 from .capture import AudioCapture
-from .transcribe import Transcriber
+from .config import AudioConfig, ThreadConfig
+from .focus import focused_app, match_rule
+from .transcribe import Transcriber, gpu_available
 from .output import TextOutput
 from .server import StateServer
 
@@ -25,7 +32,7 @@ from .server import StateServer
 class VoiceTyping:
     """Main voice typing engine."""
 
-    def __init__(self, model_size="small", device="cpu", language="en", tcp_port=None, fast=False, enable_typing=True, output_file=False, timeout=0):
+    def __init__(self, model_size="small", device="cpu", language="en", tcp_port=None, fast=False, enable_typing=True, typing_backends=None, output_file=False, flush_on_stop=False, capture_mode="vad", control_fd=None, once_timeout=0, tuning=None, app_rules=None):
         """
         Initialize voice typing engine.
 
@@ -36,7 +43,14 @@ class VoiceTyping:
             tcp_port: Optional TCP port for state monitoring
             fast: Use fast mode (int8) instead of accurate mode (float32) on CPU
             enable_typing: Enable keyboard typing (default: True, set False to only print to terminal)
+            typing_backends: Typing backends to try in order (default: the session's usual tool)
             output_file: Write transcriptions to output.txt (default: False)
+            flush_on_stop: Transcribe the utterance being recorded when stopping (default: False)
+            capture_mode: "vad" records on detected speech, "ptt" between hold and release commands
+            control_fd: Optional file descriptor the supervisor sends commands on
+            once_timeout: If set, capture a single utterance (waiting this many seconds for speech), report it and exit
+            tuning: Optional [audio], [vad] and [transcription] settings from config.toml, see _apply_tuning
+            app_rules: Optional [[app]] rules from config.toml, see _app_rule
         """
         self.model_size = model_size
         self.device = device
@@ -45,8 +59,14 @@ class VoiceTyping:
         self.fast = fast
         self.enable_typing = enable_typing
         self.output_file = output_file
-        self.timeout = timeout
-        self._last_output_time = time.time()
+        self.flush_on_stop = flush_on_stop
+        self.capture_mode = capture_mode
+        self.app_rules = app_rules or []
+        self._hold = threading.Event()  # push-to-talk key is down
+        self._hold_started = 0.0
+        self._once = None  # armed one-shot request: id, deadline, type
+        self._once_lock = threading.Lock()
+        self._last_heartbeat = 0.0
 
         # State management
         self._state = "initializing"
@@ -57,14 +77,16 @@ class VoiceTyping:
         self._paused_lock = threading.Lock()
         self._is_typing = False
         self._is_typing_lock = threading.Lock()
+        self._signalled = None  # "pause" or "resume", see _handle_signals
 
         # Initialize components
         self.capture = AudioCapture()
-        self.transcriber = Transcriber(model_size, device, language, fast)
-        self.output = TextOutput(enable_typing, output_file)
+        progress = lambda stage, progress: events.emit("loading", id=0, stage=stage, progress=progress)
+        self.transcriber = Transcriber(model_size, device, language, fast, on_progress=progress)
+        self._apply_tuning(tuning or {})
+        self.output = TextOutput(enable_typing, output_file, typing_backends)
 
-        mode = "fast" if fast else "accurate"
-        print(f"model: {model_size} | device: {device} | language: {language} | mode: {mode}\n")
+        self._announce()
 
         # Start TCP server if requested
         self.server = None
@@ -72,14 +94,22 @@ class VoiceTyping:
             self.server = StateServer(tcp_port, self._get_state_dict)
             self.server.start()
 
+        # Register signal handlers before announcing readiness (the default SIGUSR1 action kills us)
+        signal.signal(signal.SIGUSR1, self.pause_listening)
+        signal.signal(signal.SIGUSR2, self.resume_listening)
+        signal.signal(signal.SIGTERM, self.request_stop)
+        signal.signal(signal.SIGINT, self.request_stop)
+
         # Initial state
         self.state = "ready"
         # Signal to Go that system is ready (via stderr to not interfere with stdout display)
-        import sys
-        print("SYSTEM_READY", file=sys.stderr, flush=True)
+        events.emit("ready")
+
+        if once_timeout > 0:
+            self._arm_once(None, once_timeout, enable_typing)
 
-        if self.timeout > 0:
-            self._start_timeout_watcher()
+        if control_fd is not None:
+            self._start_command_reader(control_fd)
 
     @property
     def state(self):
@@ -89,9 +119,13 @@ class VoiceTyping:
 
     @state.setter
     def state(self, new_state):
-        """Set state and update display (thread-safe)."""
+        """Set state, report changes to Go and update display (thread-safe)."""
         with self._state_lock:
+            changed = self._state != new_state
             self._state = new_state
+        if changed:
+            # The Go supervisor treats these events as the source of truth
+            events.emit("state", state=new_state)
         if self.server:
             self.server.broadcast(self._get_state_dict())
         # Update terminal display
@@ -144,28 +178,236 @@ class VoiceTyping:
             "is_typing": self.is_typing
         }
 
-    def _start_timeout_watcher(self):
-        """Daemon thread: auto-pause after N seconds of no text output."""
-        def watcher():
-            while self.running:
-                time.sleep(1)
-                if self.state != 'ready':
-                    continue
-                if time.time() - self._last_output_time >= self.timeout:
-                    subprocess.run(['yap', 'pause'])
+    def _announce(self):
+        """Print the active settings and report them to Go."""
+        mode = "fast" if self.fast else "accurate"
+        print(f"model: {self.model_size} | device: {self.device} | language: {self.language} | mode: {mode}\n")
+        events.emit("engine", model=self.model_size, device=self.device, language=self.language or "", mode=mode)
+
+    def _start_command_reader(self, fd):
+        """Daemon thread: apply commands sent by the supervisor, one JSON object per line."""
+        def reader():
+            with os.fdopen(fd, 'r') as commands:
+                for line in commands:
+                    try:
+                        request = json.loads(line)
+                    except ValueError:
+                        continue
+                    cmd = request.get("cmd")
+                    if cmd == "configure":
+                        # Loading a model takes a while; keep hold/release responsive meanwhile
+                        threading.Thread(
+                            target=self._configure,
+                            args=(request.get("id"), request.get("settings", {})),
+                            daemon=True
+                        ).start()
+                    elif cmd == "once":
+                        self._arm_once(request.get("id"), request.get("timeout", 10), request.get("type", False))
+                    elif cmd in ("hold", "release"):
+                        self._push_to_talk(request.get("id"), cmd == "hold")
+                    else:
+                        events.emit("reply", id=request.get("id"), ok=False, error=f"unknown command: {request.get('cmd')}")
+
+            # The supervisor exited without stopping us; in our own process
+            # group no terminal signal would
+            self.running = False
 
-        t = threading.Thread(target=watcher, daemon=True)
+        t = threading.Thread(target=reader, daemon=True)
         t.start()
 
+    def _configure(self, request_id, settings):
+        """Apply changed settings without interrupting capture, then reply to the supervisor."""
+        try:
+            model_size = settings.get("model", self.model_size)
+            device = settings.get("device", self.device)
+            fast = settings.get("fast", self.fast)
+            language = self.language
+            if "language" in settings:
+                language = None if settings["language"] in ["", "auto"] else settings["language"]
+
+            if device == "cuda":
+                device = "gpu"
+            if device == "gpu" and not gpu_available():
+                raise RuntimeError("GPU not available")
+
+            if (model_size, device, fast) != (self.model_size, self.device, self.fast):
+                # Load the new model alongside the old one, which keeps transcribing meanwhile
+                progress = lambda stage, progress: events.emit("loading", id=request_id, stage=stage, progress=progress)
+                old = self.transcriber
+                try:
+                    self.transcriber = Transcriber(
+                        model_size, device, language, fast, on_progress=progress,
+                        beam_size=old.beam_size, min_confidence=old.min_confidence, min_audio_duration=old.min_audio_duration
+                    )
+                except Exception as e:
+                    # The old model stays; the reply carries the error too
+                    events.warning(f"failed to load {model_size}: {e}")
+                    raise
+                self.model_size, self.device, self.fast = model_size, device, fast
+            else:
+                self.transcriber.language = language
+            self.language = language
+
+            if "enable_typing" in settings:
+                self.enable_typing = settings["enable_typing"]
+                self.output.enable_typing = self.enable_typing
+            if settings.get("typing_backends"):
+                self.output.backends = list(settings["typing_backends"])
+            if "output_file" in settings:
+                self.output_file = settings["output_file"]
+                self.output.set_output_file(self.output_file)
+            if "flush_on_stop" in settings:
+                self.flush_on_stop = settings["flush_on_stop"]
+            if "capture_mode" in settings:
+                self.capture_mode = settings["capture_mode"]
+                if self.capture_mode != "ptt":
+                    self._hold.clear()
+            self._apply_tuning(settings)
+            if "app_rules" in settings:
+                self.app_rules = settings["app_rules"] or []
+
+            self.output.clear_status_line()
+            self._announce()
+            if self.server:
+                self.server.broadcast(self._get_state_dict())
+            events.emit("reply", id=request_id, ok=True)
+        except Exception as e:
+            events.emit("reply", id=request_id, ok=False, error=str(e))
+
+    def _apply_tuning(self, settings):
+        """Apply the tuning keys present in settings (the rest of it is ignored)."""
+        if "silence_duration" in settings:
+            self.capture.silence_duration = settings["silence_duration"]
+        if "pre_buffer" in settings:
+            self.capture.set_pre_buffer(settings["pre_buffer"])
+        if "vad_aggressiveness" in settings:
+            self.capture.set_vad_aggressiveness(settings["vad_aggressiveness"])
+        if "beam_size" in settings:
+            self.transcriber.beam_size = settings["beam_size"]
+        if "min_confidence" in settings:
+            self.transcriber.min_confidence = settings["min_confidence"]
+        if "min_audio_duration" in settings:
+            self.transcriber.min_audio_duration = settings["min_audio_duration"]
+
+    def _push_to_talk(self, request_id, down):
+        """Start (hold) or end (release) a push-to-talk utterance; the main loop does the recording."""
+        if self.capture_mode != "ptt":
+            events.emit("reply", id=request_id, ok=False, error='capture_mode is not "ptt"')
+            return
+        if down and self.paused:
+            events.emit("reply", id=request_id, ok=False, error="paused (run yap resume first)")
+            return
+
+        if down:
+            if not self._hold.is_set():
+                self._hold_started = time.time()
+                self._hold.set()
+        else:
+            self._hold.clear()
+        events.emit("reply", id=request_id, ok=True)
+
+    def _push_to_talk_step(self, chunk):
+        """Record while the key is held, no VAD gating; transcribe once released."""
+        held = self._hold.is_set()
+        if held and time.time() - self._hold_started > AudioConfig.PTT_MAX_HOLD_SEC:
+            self._hold.clear()
+            held = False
+
+        if held and not self.capture.is_recording:
+            # Only what is said after the key press, not the pre-buffer
+            self.capture.start_recording(with_pre_buffer=False)
+            self.capture.add_to_recording(chunk)
+            self.state = "listening"
+        elif held:
+            self.capture.add_to_recording(chunk)
+        elif self.capture.is_recording:
+            self._process_recording()
+            # A pause may have arrived mid-transcription
+            self._handle_signals()
+            self.state = "paused" if self.paused else "ready"
+            self.capture.reset_buffers()
+
+    def _arm_once(self, request_id, timeout, type_text):
+        """Hand the next utterance to a one-shot request instead of typing it."""
+        with self._once_lock:
+            if self._once:
+                events.emit("reply", id=request_id, ok=False, error="another yap once is in progress")
+                return
+            if self.paused:
+                events.emit("reply", id=request_id, ok=False, error="paused")
+                return
+            self._once = {
+                "id": request_id,
+                "deadline": time.time() + timeout,
+                "timeout": timeout,
+                "type": type_text,
+                # An utterance already being recorded started before the request
+                "skip": self.capture.is_recording,
+            }
+
+    def _take_once(self):
+        """Disarm and return the pending one-shot request, if any."""
+        with self._once_lock:
+            once, self._once = self._once, None
+            if once and once["skip"]:
+                # Leave it armed for the next utterance
+                once["skip"] = False
+                self._once, once = once, None
+            return once
+
+    def _check_once(self):
+        """Give up on a one-shot request if nobody spoke in time or listening was paused."""
+        with self._once_lock:
+            once = self._once
+        if not once or self.capture.is_recording:
+            return
+        if self.paused:
+            self._finish_once(self._take_once(), {"ok": False, "reason": "paused", "error": "paused"})
+        elif time.time() > once["deadline"]:
+            error = f"no speech within {once['timeout']}s"
+            self._finish_once(self._take_once(), {"ok": False, "reason": "timeout", "error": error})
+
+    def _finish_once(self, once, result):
+        """Report a one-shot result to its requester."""
+        if once is None:
+            return
+        if once["id"] is None:
+            # Started with --once: the result is the whole job
+            events.emit("reply", id=0, **result)
+            self.running = False
+        else:
+            events.emit("reply", id=once["id"], **result)
+
     def pause_listening(self, _signum=None, _frame=None):
-        """Pause listening (SIGUSR1 handler)."""
+        """Ask the main loop to pause (SIGUSR1 handler)."""
+        self._signalled = "pause"
+
+    def resume_listening(self, _signum=None, _frame=None):
+        """Ask the main loop to resume (SIGUSR2 handler)."""
+        self._signalled = "resume"
+
+    def _handle_signals(self):
+        """
+        Apply the last pause or resume signal. The handlers only record it: they
+        interrupt the main thread, which may be holding a state lock or writing
+        an event at the time.
+        """
+        request, self._signalled = self._signalled, None
+        if request == "pause":
+            self._pause()
+        elif request == "resume":
+            self._resume()
+
+    def _pause(self):
+        """Pause listening."""
         if not self.paused:
+            self._hold.clear()
             self.paused = True
             self.capture.pause_capture()
             self.state = "paused"
 
-    def resume_listening(self, _signum=None, _frame=None):
-        """Resume listening (SIGUSR2 handler)."""
+    def _resume(self):
+        """Resume listening."""
         if self.paused:
             self.paused = False
             self.capture.reset_buffers()
@@ -174,20 +416,84 @@ class VoiceTyping:
             # Restart audio capture (reopens stream if closed)
             self.capture.resume_capture()
 
-            self._last_output_time = time.time()
             self.state = "ready"
 
+    def request_stop(self, _signum=None, _frame=None):
+        """Finish the current utterance, then exit the main loop (SIGTERM/SIGINT handler)."""
+        if not self.running:
+            # Asked twice: stop waiting for in-flight work
+            raise SystemExit(1)
+        self.running = False
+
+    def _app_rule(self):
+        """The [[app]] rule for the focused window plus its class as "app", or {} if none matches."""
+        if not self.app_rules:
+            return {}
+        app = focused_app()
+        rule = match_rule(self.app_rules, app) if app else None
+        return dict(rule, app=app) if rule else {}
+
+    def _type(self, text, rule):
+        """Type text with the overrides of the matching [[app]] rule."""
+        self.is_typing = True
+        self.output.type_text(
+            text,
+            enable_typing=rule.get("enable_typing", True),
+            trailing_space=rule.get("trailing_space", True),
+            press_enter=rule.get("press_enter", False)
+        )
+        self.is_typing = False
+
+    def _process_recording(self):
+        """Transcribe the current recording and output the text."""
+        self.state = "processing"
+        recording = self.capture.get_recording()
+
+        # The window focused when the utterance ends is where the text goes
+        rule = self._app_rule()
+        language = self.language
+        if "language" in rule:
+            language = None if rule["language"] in ["", "auto"] else rule["language"]
+
+        started = time.time()
+        text, confidence = self.transcriber.transcribe(recording, language)
+        latency = time.time() - started
+
+        once = self._take_once()
+        if once:
+            if not text:
+                self.output.clear_status_line()
+                self._finish_once(once, {"ok": False, "reason": "no_speech", "error": "no speech recognized"})
+                return
+            if once["type"]:
+                self._type(text, rule)
+            self._finish_once(once, {"ok": True, "text": text})
+            return
+
+        if text:
+            self._type(text, rule)
+            duration = sum(len(chunk) for chunk in recording) / 2 / AudioConfig.RATE
+            events.emit(
+                "transcription",
+                text=text,
+                duration=round(duration, 3),
+                latency=round(latency, 3),
+                confidence=confidence,
+                app=rule.get("app")
+            )
+        else:
+            self.output.clear_status_line()
+
     def run(self):
         """Main event loop."""
-        # Register signal handlers
-        signal.signal(signal.SIGUSR1, self.pause_listening)
-        signal.signal(signal.SIGUSR2, self.resume_listening)
-
         # Start audio capture
         self.capture.start()
 
         try:
             while self.running:
+                self._handle_signals()
+                self._check_once()
+
                 # Get next audio chunk
                 try:
                     chunk = self.capture.get_chunk()
@@ -198,9 +504,20 @@ class VoiceTyping:
                 if self.paused:
                     continue
 
+                # Tell the Go watchdog audio is still flowing
+                now = time.time()
+                if now - self._last_heartbeat >= ThreadConfig.HEARTBEAT_INTERVAL_SEC:
+                    self._last_heartbeat = now
+                    events.emit("heartbeat")
+
                 # Add to pre-buffer
                 self.capture.add_to_pre_buffer(chunk)
 
+                # A one-shot request uses VAD even in push-to-talk mode
+                if self.capture_mode == "ptt" and not self._once:
+                    self._push_to_talk_step(chunk)
+                    continue
+
                 # Check for speech
                 is_speech = self.capture.is_speech(chunk)
 
@@ -220,18 +537,11 @@ class VoiceTyping:
 
                         if self.capture.should_stop_recording():
                             # Silence threshold exceeded - transcribe
-                            self.state = "processing"
-                            text = self.transcriber.transcribe(self.capture.get_recording())
-
-                            if text:
-                                self.is_typing = True
-                                self.output.type_text(text)
-                                self.is_typing = False
-                                self._last_output_time = time.time()
-                            else:
-                                self.output.clear_status_line()
-
-                            self.state = "ready"
+                            self._process_recording()
+
+                            # A pause may have arrived mid-transcription
+                            self._handle_signals()
+                            self.state = "paused" if self.paused else "ready"
                             self.capture.reset_buffers()
                     else:
                         # Speech continues - reset silence counter
@@ -247,7 +557,12 @@ class VoiceTyping:
         """Clean up resources."""
         self.output.clear_status_line()
         self.running = False
+        if self.flush_on_stop and self.capture.is_recording and not self.paused:
+            # Stopped mid-utterance: don't lose what was said so far
+            self._process_recording()
+            self.output.clear_status_line()
         time.sleep(0.1)  # Let threads finish
         self.capture.stop()
+        self.output.close()
         if self.server:
             self.server.stop()
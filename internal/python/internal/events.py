@@ -0,0 +1,50 @@
+"""
+Structured event stream to the Go supervisor.
+
+Handles:
+- One JSON object per line on a dedicated file descriptor (--event-fd)
+- Protocol versioning (every event carries "v")
+- Thread-safe writes (not from signal handlers, which could interrupt one)
+
+Event types: ready, state, heartbeat, engine, transcription, loading, reply,
+warning, fatal. Without an event descriptor (engine run by hand) events are dropped.
+"""
+
+import json
+import os
+import threading
+
+PROTOCOL_VERSION = 1
+
+_stream = None
+_lock = threading.Lock()
+
+
+def open_stream(fd):
+    """Start writing events to the given file descriptor."""
+    global _stream
+    _stream = os.fdopen(fd, 'w', encoding='utf-8')
+
+
+def emit(event_type, **fields):
+    """Send one event to the supervisor."""
+    if _stream is None:
+        return
+    line = json.dumps({"v": PROTOCOL_VERSION, "type": event_type, **fields})
+    with _lock:
+        try:
+            _stream.write(line + "\n")
+            _stream.flush()
+        except (OSError, ValueError, RuntimeError):
+            # Supervisor went away (or the stream was interrupted mid-write); nothing to report to
+            pass
+
+
+def warning(message):
+    """Report a recoverable problem."""
+    emit("warning", message=message)
+
+
+def fatal(message):
+    """Report why the engine is about to exit."""
+    emit("fatal", message=message)
@@ -0,0 +1,122 @@
+"""
+Focused window detection for per-application rules ([[app]] in config.toml).
+
+Handles:
+- Finding the class (X11) or app_id (Wayland) of the focused window with
+  hyprctl, swaymsg, a one-off KWin script or xdotool, whichever fits the session
+- Matching it against the rules' glob patterns
+"""
+
+import fnmatch
+import json
+import os
+import subprocess
+import tempfile
+import time
+import uuid
+
+from .config import FocusConfig
+
+# KWin 6 calls it activeWindow, KWin 5 activeClient
+_KWIN_SCRIPT = """
+const w = workspace.activeWindow || workspace.activeClient;
+print("%s " + (w ? w.resourceClass : ""));
+"""
+
+
+def focused_app():
+    """Return the focused window's class or app_id, or "" if it cannot be found."""
+    for detect in _detectors():
+        try:
+            app = detect()
+        except (OSError, subprocess.SubprocessError, ValueError, AttributeError):
+            continue
+        if app:
+            return app
+    return ""
+
+
+def match_rule(rules, app):
+    """Return the first rule whose match pattern fits app (case-insensitive), or None."""
+    app = app.lower()
+    for rule in rules:
+        if fnmatch.fnmatchcase(app, rule.get("match", "").lower()):
+            return rule
+    return None
+
+
+def _detectors():
+    """Detection methods worth trying in this session, most specific first."""
+    detectors = []
+    if os.environ.get("HYPRLAND_INSTANCE_SIGNATURE"):
+        detectors.append(_hyprland)
+    if os.environ.get("SWAYSOCK"):
+        detectors.append(_sway)
+    if "KDE" in os.environ.get("XDG_CURRENT_DESKTOP", "").upper() and os.environ.get("WAYLAND_DISPLAY"):
+        detectors.append(_kwin)
+    if os.environ.get("DISPLAY"):
+        # X11, or an XWayland window elsewhere
+        detectors.append(_x11)
+    return detectors
+
+
+def _run(cmd):
+    return subprocess.run(
+        cmd,
+        capture_output=True,
+        text=True,
+        check=True,
+        timeout=FocusConfig.COMMAND_TIMEOUT_SEC
+    ).stdout
+
+
+def _hyprland():
+    return json.loads(_run(["hyprctl", "activewindow", "-j"])).get("class", "")
+
+
+def _sway():
+    nodes = [json.loads(_run(["swaymsg", "-t", "get_tree"]))]
+    while nodes:
+        node = nodes.pop()
+        if node.get("focused") and node.get("type") in ("con", "floating_con"):
+            # Native Wayland windows have an app_id, XWayland ones a class
+            return node.get("app_id") or node.get("window_properties", {}).get("class", "")
+        nodes.extend(node.get("nodes", []))
+        nodes.extend(node.get("floating_nodes", []))
+    return ""
+
+
+def _x11():
+    return _run(["xdotool", "getactivewindow", "getwindowclassname"]).strip()
+
+
+def _kwin():
+    """Ask KWin through its scripting interface; the script's print() ends up in the user journal."""
+    marker = "yap-focus-" + uuid.uuid4().hex[:8]
+    since = int(time.time()) - 1
+    dbus = ["dbus-send", "--session", "--print-reply=literal", "--dest=org.kde.KWin"]
+
+    with tempfile.NamedTemporaryFile("w", suffix=".js") as script:
+        script.write(_KWIN_SCRIPT % marker)
+        script.flush()
+        reply = _run(dbus + ["/Scripting", "org.kde.kwin.Scripting.loadScript", f"string:{script.name}", f"string:{marker}"])
+        script_id = reply.split()[-1]
+        try:
+            # Object path of KWin 6, then of KWin 5
+            for path in (f"/Scripting/Script{script_id}", f"/{script_id}"):
+                try:
+                    _run(dbus + [path, "org.kde.kwin.Script.run"])
+                    break
+                except subprocess.CalledProcessError:
+                    continue
+
+            deadline = time.time() + FocusConfig.KWIN_TIMEOUT_SEC
+            while time.time() < deadline:
+                journal = _run(["journalctl", "--user", "--output=cat", f"--since=@{since}"])
+                for line in journal.splitlines():
+                    if marker + " " in line:
+                        return line.split(marker + " ", 1)[1].strip()
+                time.sleep(FocusConfig.KWIN_POLL_SEC)
+            return ""
+        finally:
+            _run(dbus + ["/Scripting", "org.kde.kwin.Scripting.unloadScript", f"string:{marker}"])
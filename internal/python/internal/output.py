@@ -3,41 +3,66 @@ Text output to terminal and active window.
 
 Handles:
 - Printing transcribed text to terminal
-- Typing text into active window via wtype (Wayland) or xdotool (X11)
+- Typing text into active window through a chain of backends (wtype, xdotool,
+  ydotool, dotool or a built-in uinput keyboard), falling back in order
 - Clearing ephemeral status lines
 """
 
 import os
 import subprocess
+import sys
+from . import events
 from .config import DisplayConfig
+from .uinput import VirtualKeyboard
+
+
+class TypingFailed(Exception):
+    """A backend could not type; the next one in the chain is tried."""
+
+
+def default_backends():
+    """Backends to try when none are given: the session's usual tool."""
+    session_type = os.environ.get('XDG_SESSION_TYPE', '').lower()
+    if session_type == 'wayland':
+        return ['wtype']
+    if session_type == 'x11':
+        return ['xdotool']
+    return ['wtype', 'xdotool']
 
 
 class TextOutput:
     """Manages text output to terminal and active window."""
 
-    def __init__(self, enable_typing=True, output_file=False):
+    def __init__(self, enable_typing=True, output_file=False, backends=None):
         """
         Initialize text output.
 
         Args:
             enable_typing: Enable keyboard typing (default: True, set False to only print to terminal)
             output_file: Write transcriptions to output.txt (default: False)
+            backends: Typing backends to try in order (default: the session's usual tool)
         """
         self.enable_typing = enable_typing
-        self.output_file = output_file
+        self.set_output_file(output_file)
+        self.backends = list(backends or default_backends())
+        self._keyboard = VirtualKeyboard()
+
+    def set_output_file(self, enabled):
+        """
+        Enable or disable writing transcriptions to output.txt.
+
+        Args:
+            enabled: Write transcriptions to output.txt
+        """
+        self.output_file = enabled
 
         # Get output file path if enabled
         self.output_file_path = None
-        if output_file:
+        if enabled:
             xdg_config = os.environ.get('XDG_CONFIG_HOME', os.path.join(os.path.expanduser('~'), '.config'))
             config_dir = os.path.join(xdg_config, 'yappers-of-linux')
             self.output_file_path = os.path.join(config_dir, "output.txt")
 
-        # Detect session type (Wayland vs X11)
-        self.session_type = os.environ.get('XDG_SESSION_TYPE', '').lower()
-        self.is_wayland = self.session_type == 'wayland'
-        self.is_x11 = self.session_type == 'x11'
-
     def clear_status_line(self):
         """Clear ephemeral status line in terminal."""
         print(f"\r{' ' * DisplayConfig.STATUS_LINE_WIDTH}\r", end='', flush=True)
@@ -62,12 +87,15 @@ class TextOutput:
         self.clear_status_line()
         print(f"{text}\n", flush=True)
 
-    def type_text(self, text):
+    def type_text(self, text, enable_typing=True, trailing_space=True, press_enter=False):
         """
-        Type text into active window using wtype or xdotool.
+        Type text into active window with the first backend that works.
 
         Args:
-            text: Text to type (space will be appended automatically)
+            text: Text to type
+            enable_typing: False to only print this text (cannot enable typing that is off)
+            trailing_space: Append a space, so the next utterance is separated
+            press_enter: Press Enter after the text
         """
         # Print to terminal first for immediate feedback
         self.print_text(text)
@@ -82,51 +110,92 @@ class TextOutput:
                 pass
 
         # Skip keyboard typing if disabled
-        if not self.enable_typing:
+        if not (self.enable_typing and enable_typing):
             return
 
-        # Type into active window based on session type
-        if self.is_wayland:
-            self._type_wayland(text)
-        elif self.is_x11:
-            self._type_x11(text)
-        else:
-            # Unknown session type, try both
-            self._type_with_fallback(text)
-
-    def _type_wayland(self, text):
-        """Type text on Wayland using wtype."""
-        try:
-            subprocess.run(
-                ['wtype', text + ' '],
-                capture_output=True,
-                check=True
-            )
-        except FileNotFoundError:
-            print(f"\rerror: wtype not installed (required for Wayland)")
-        except subprocess.CalledProcessError:
-            print(f"\rerror: wtype failed")
+        if trailing_space:
+            text += ' '
+        self.send_keys(text, press_enter)
 
-    def _type_x11(self, text):
-        """Type text on X11 using xdotool."""
-        try:
-            subprocess.run(
-                ['xdotool', 'type', '--delay', '10', text + ' '],
-                capture_output=True,
-                text=True,
-                check=True
-            )
-        except FileNotFoundError:
-            print(f"\rerror: xdotool not installed (required for X11)")
-        except subprocess.CalledProcessError:
-            print(f"\rerror: xdotool failed")
+    def send_keys(self, text, press_enter=False):
+        """
+        Type text, without printing it, trying each backend in order. One that
+        works after another failed moves to the front, so a backend the desktop
+        does not support is not tried again and again.
 
-    def _type_with_fallback(self, text):
-        """Try wtype, then xdotool (for unknown session types)."""
-        for cmd in (['wtype', text + ' '], ['xdotool', 'type', '--delay', '10', text + ' ']):
+        Returns:
+            The name of the backend that typed, or None if none could
+        """
+        problems = []
+        for name in list(self.backends):
+            backend = self.BACKENDS.get(name)
+            if backend is None:
+                problems.append(f"{name}: unknown backend")
+                continue
             try:
-                subprocess.run(cmd, capture_output=True, check=True)
-                return
-            except (subprocess.CalledProcessError, FileNotFoundError):
+                backend(self, text, press_enter)
+            except TypingFailed as e:
+                problems.append(f"{name}: {e}")
                 continue
-        print(f"\rerror: failed to type (install wtype or xdotool)")
+            if name != self.backends[0]:
+                self.backends.remove(name)
+                self.backends.insert(0, name)
+            return name
+
+        self._warn("failed to type (" + "; ".join(problems) + ")" if problems else "failed to type (no typing backend)")
+        return None
+
+    def close(self):
+        """Remove the virtual keyboard, if the uinput backend made one."""
+        self._keyboard.close()
+
+    def _warn(self, message):
+        """Show a typing problem in the terminal and report it to the supervisor."""
+        print(f"\rerror: {message}", file=sys.stderr)
+        events.warning(message)
+
+    def _run(self, cmd, stdin=None):
+        """Run a typing tool, turning its failure into TypingFailed."""
+        try:
+            subprocess.run(cmd, input=stdin, capture_output=True, text=True, check=True)
+        except FileNotFoundError:
+            raise TypingFailed("not installed")
+        except subprocess.CalledProcessError as e:
+            # e.g. wtype on compositors without the virtual keyboard protocol
+            reason = e.stderr.strip().splitlines()[-1] if e.stderr.strip() else f"exit status {e.returncode}"
+            raise TypingFailed(reason)
+
+    def _type_wtype(self, text, press_enter):
+        self._run(['wtype', text] + (['-k', 'Return'] if press_enter else []))
+
+    def _type_xdotool(self, text, press_enter):
+        # xdotool runs chained commands in order
+        self._run(['xdotool', 'type', '--delay', '10', text] + (['key', 'Return'] if press_enter else []))
+
+    def _type_ydotool(self, text, press_enter):
+        # From stdin, so text starting with "-" is not taken for an option
+        self._run(['ydotool', 'type', '--file', '-'], stdin=text)
+        if press_enter:
+            self._run(['ydotool', 'key', '28:1', '28:0'])
+
+    def _type_dotool(self, text, press_enter):
+        # One command per line: "type" takes the rest of the line
+        commands = [f"type {line}" if line else "" for line in text.split('\n')]
+        script = "\nkey enter\n".join(commands)
+        if press_enter:
+            script += "\nkey enter"
+        self._run(['dotool'], stdin=script + "\n")
+
+    def _type_uinput(self, text, press_enter):
+        try:
+            self._keyboard.type(text, press_enter)
+        except (ValueError, OSError) as e:
+            raise TypingFailed(str(e))
+
+    BACKENDS = {
+        'wtype': _type_wtype,
+        'xdotool': _type_xdotool,
+        'ydotool': _type_ydotool,
+        'dotool': _type_dotool,
+        'uinput': _type_uinput,
+    }
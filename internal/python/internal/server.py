@@ -11,6 +11,7 @@ import threading
 import json
 import time
 
+from . import events
 from .config import TCPConfig
 
 
@@ -100,6 +101,7 @@ class StateServer:
                     break
         except OSError as e:
             print(f"tcp error: {e}")
+            events.warning(f"tcp server on port {self.port}: {e}")
         finally:
             if self._server_socket:
                 try:
@@ -5,18 +5,46 @@ Handles:
 - Whisper model loading and initialization
 - Model warmup to avoid first-run delay
 - Audio transcription with configurable parameters
+- Whole-file transcription with segment timestamps
 """
 
+import math
+
 import numpy as np
-from faster_whisper import WhisperModel
+from faster_whisper import WhisperModel, decode_audio
 
 from .config import AudioConfig, TranscriptionConfig
 
 
+def gpu_available():
+    """Check whether CUDA can be used for transcription."""
+    try:
+        import ctranslate2
+        return ctranslate2.get_cuda_device_count() > 0
+    except Exception:
+        return False
+
+
+def _model_cached(model_size):
+    """Check whether a model is already downloaded (assumed so if unsure)."""
+    try:
+        from faster_whisper.utils import download_model
+    except ImportError:
+        return True
+    try:
+        download_model(model_size, local_files_only=True)
+        return True
+    except Exception:
+        return False
+
+
 class Transcriber:
     """Whisper-based speech transcription."""
 
-    def __init__(self, model_size="small", device="cpu", language="en", fast=False):
+    def __init__(self, model_size="small", device="cpu", language="en", fast=False, on_progress=None, warmup=True,
+                 beam_size=TranscriptionConfig.BEAM_SIZE,
+                 min_confidence=TranscriptionConfig.MIN_CONFIDENCE,
+                 min_audio_duration=TranscriptionConfig.MIN_AUDIO_DURATION_SEC):
         """
         Initialize Whisper model.
 
@@ -25,11 +53,20 @@ class Transcriber:
             device: Compute device (cpu, gpu)
             language: Language code (en, es, fr, etc.)
             fast: Use fast mode (int8) instead of accurate mode (float32) on CPU
+            on_progress: Optional callback receiving a short stage description and
+                the rough fraction (0-1) of the load done so far
+            warmup: Run a dummy transcription so the first utterance is not delayed
+            beam_size: Candidates kept while decoding
+            min_confidence: Average log-probability below which live segments are dropped
+            min_audio_duration: Seconds; shorter utterances are not transcribed
         """
         self.model_size = model_size
         self.device = device
         self.language = language
         self.fast = fast
+        self.beam_size = beam_size
+        self.min_confidence = min_confidence
+        self.min_audio_duration = min_audio_duration
 
         # Map 'gpu' to 'cuda' for faster-whisper backend
         whisper_device = "cuda" if device == "gpu" else "cpu"
@@ -39,25 +76,34 @@ class Transcriber:
         else:
             compute_type = TranscriptionConfig.COMPUTE_TYPE_GPU
 
+        if on_progress:
+            if not _model_cached(model_size):
+                on_progress(f"downloading {model_size}", 0.0)
+            on_progress(f"loading {model_size}", 0.5)
         self.model = WhisperModel(model_size, device=whisper_device, compute_type=compute_type)
 
         # Warm up model to avoid first-run delay
-        self._warmup()
+        if warmup:
+            if on_progress:
+                on_progress("warming up", 0.9)
+            self._warmup()
 
     def _warmup(self):
         """Run dummy transcription to initialize model."""
         dummy_audio = np.zeros(AudioConfig.RATE, dtype=np.float32)
         list(self.model.transcribe(dummy_audio, language=self.language))
 
-    def transcribe(self, audio_data):
+    def transcribe(self, audio_data, language=...):
         """
         Transcribe audio to text.
 
         Args:
             audio_data: List of audio chunks (bytes)
+            language: Language code for this utterance, None to auto-detect (default: self.language)
 
         Returns:
-            Transcribed text string (empty if no speech detected)
+            Tuple of (text, confidence): text is empty if no speech was detected,
+            confidence is the 0-1 average over the kept segments (None without text)
         """
         # Convert bytes to numpy array
         audio_np = np.frombuffer(b''.join(audio_data), dtype=np.int16).astype(np.float32) / 32768.0
@@ -68,14 +114,17 @@ class Transcriber:
             audio_np = audio_np / max_val
 
         # Skip if audio too short
-        if len(audio_np) < TranscriptionConfig.MIN_AUDIO_DURATION_SEC * AudioConfig.RATE:
-            return ""
+        if len(audio_np) < self.min_audio_duration * AudioConfig.RATE:
+            return "", None
+
+        if language is ...:
+            language = self.language
 
         # Transcribe
         segments, _ = self.model.transcribe(
             audio_np,
-            language=self.language,
-            beam_size=TranscriptionConfig.BEAM_SIZE,
+            language=language,
+            beam_size=self.beam_size,
             best_of=TranscriptionConfig.BEST_OF,
             temperature=TranscriptionConfig.TEMPERATURE,
             without_timestamps=True,
@@ -88,10 +137,53 @@ class Transcriber:
         )
 
         # Join segments (filter by confidence to reduce hallucinations)
-        full_text = " ".join(
-            segment.text.strip()
-            for segment in segments
-            if segment.text.strip() and segment.avg_logprob > TranscriptionConfig.MIN_CONFIDENCE
+        kept = [
+            segment for segment in segments
+            if segment.text.strip() and segment.avg_logprob > self.min_confidence
+        ]
+        full_text = " ".join(segment.text.strip() for segment in kept)
+        if not kept:
+            return full_text, None
+
+        confidence = sum(math.exp(segment.avg_logprob) for segment in kept) / len(kept)
+        return full_text, round(confidence, 3)
+
+    def transcribe_file(self, source):
+        """
+        Transcribe a whole recording with segment timestamps.
+
+        Args:
+            source: File path or binary file object (WAV, FLAC, OGG, ...), resampled to 16 kHz
+
+        Returns:
+            Tuple of (segments, info): segments are dicts with start, end, text and
+            confidence; info has the detected language and the duration in seconds
+        """
+        audio = decode_audio(source, sampling_rate=AudioConfig.RATE)
+
+        segments, info = self.model.transcribe(
+            audio,
+            language=self.language,
+            beam_size=self.beam_size,
+            best_of=TranscriptionConfig.BEST_OF,
+            temperature=TranscriptionConfig.TEMPERATURE,
+            vad_filter=True,
+            vad_parameters=dict(
+                min_silence_duration_ms=TranscriptionConfig.VAD_MIN_SILENCE_MS,
+                speech_pad_ms=TranscriptionConfig.VAD_SPEECH_PAD_MS
+            )
         )
 
-        return full_text
+        # Low-confidence segments are kept (unlike live typing), the caller sees the score
+        result = [
+            {
+                "start": round(segment.start, 3),
+                "end": round(segment.end, 3),
+                "text": segment.text.strip(),
+                "confidence": round(math.exp(segment.avg_logprob), 3),
+            }
+            for segment in segments
+            if segment.text.strip()
+        ]
+
+        return result, {"language": info.language, "duration": round(info.duration, 3)}
@@ -0,0 +1,114 @@
+"""
+Virtual keyboard on /dev/uinput.
+
+Handles:
+- Creating a keyboard device through the kernel's uinput interface (no tools needed)
+- Typing ASCII text by US layout key positions (other layouts map them to their own characters)
+"""
+
+import fcntl
+import os
+import struct
+import time
+
+UINPUT_PATH = "/dev/uinput"
+
+# linux/uinput.h and linux/input-event-codes.h
+UI_SET_EVBIT = 0x40045564
+UI_SET_KEYBIT = 0x40045565
+UI_DEV_CREATE = 0x5501
+UI_DEV_DESTROY = 0x5502
+EV_SYN, EV_KEY = 0x00, 0x01
+SYN_REPORT = 0
+BUS_VIRTUAL = 0x06
+
+KEY_ENTER = 28
+KEY_LEFTSHIFT = 42
+
+# Character -> (key code, shift)
+_KEYS = {" ": (57, False), "\t": (15, False), "\n": (KEY_ENTER, False)}
+_UNSHIFTED = {
+    "1": 2, "2": 3, "3": 4, "4": 5, "5": 6, "6": 7, "7": 8, "8": 9, "9": 10, "0": 11, "-": 12, "=": 13,
+    "q": 16, "w": 17, "e": 18, "r": 19, "t": 20, "y": 21, "u": 22, "i": 23, "o": 24, "p": 25, "[": 26, "]": 27,
+    "a": 30, "s": 31, "d": 32, "f": 33, "g": 34, "h": 35, "j": 36, "k": 37, "l": 38, ";": 39, "'": 40, "`": 41,
+    "\\": 43, "z": 44, "x": 45, "c": 46, "v": 47, "b": 48, "n": 49, "m": 50, ",": 51, ".": 52, "/": 53,
+}
+_SHIFTED = dict(zip('!@#$%^&*()_+{}:"~|<>?', '1234567890-=[];\'`\\,./'))
+for _char, _code in _UNSHIFTED.items():
+    _KEYS[_char] = (_code, False)
+    if _char.isalpha():
+        _KEYS[_char.upper()] = (_code, True)
+for _char, _base in _SHIFTED.items():
+    _KEYS[_char] = (_UNSHIFTED[_base], True)
+
+
+class VirtualKeyboard:
+    """A uinput keyboard, created on first use and kept for the engine's lifetime."""
+
+    KEY_DELAY_SEC = 0.004  # some applications drop keys sent faster
+    SETTLE_SEC = 0.3  # for the compositor to pick up a new device
+
+    def __init__(self):
+        self._fd = None
+
+    def type(self, text, press_enter=False):
+        """
+        Type text, then Enter if asked.
+
+        Raises:
+            ValueError: text has characters outside the layout
+            OSError: /dev/uinput cannot be opened
+        """
+        missing = sorted({char for char in text if char not in _KEYS})
+        if missing:
+            raise ValueError(f"no key for {''.join(missing)!r}")
+
+        self._open()
+        for char in text:
+            code, shift = _KEYS[char]
+            self._tap(code, shift)
+        if press_enter:
+            self._tap(KEY_ENTER, False)
+
+    def close(self):
+        """Remove the device, once the last keys had time to arrive."""
+        if self._fd is not None:
+            time.sleep(self.SETTLE_SEC)
+            try:
+                fcntl.ioctl(self._fd, UI_DEV_DESTROY)
+            finally:
+                os.close(self._fd)
+                self._fd = None
+
+    def _open(self):
+        if self._fd is not None:
+            return
+        fd = os.open(UINPUT_PATH, os.O_WRONLY | os.O_NONBLOCK)
+        try:
+            fcntl.ioctl(fd, UI_SET_EVBIT, EV_KEY)
+            for code in {code for code, _ in _KEYS.values()} | {KEY_ENTER, KEY_LEFTSHIFT}:
+                fcntl.ioctl(fd, UI_SET_KEYBIT, code)
+
+            # struct uinput_user_dev: name, input_id, ff_effects_max, abs{max,min,fuzz,flat}[64]
+            device = struct.pack("80sHHHHi256i", b"yap virtual keyboard", BUS_VIRTUAL, 0, 0, 1, 0, *([0] * 256))
+            os.write(fd, device)
+            fcntl.ioctl(fd, UI_DEV_CREATE)
+        except OSError:
+            os.close(fd)
+            raise
+        self._fd = fd
+        time.sleep(self.SETTLE_SEC)
+
+    def _tap(self, code, shift):
+        if shift:
+            self._key(KEY_LEFTSHIFT, 1)
+        self._key(code, 1)
+        self._key(code, 0)
+        if shift:
+            self._key(KEY_LEFTSHIFT, 0)
+        time.sleep(self.KEY_DELAY_SEC)
+
+    def _key(self, code, value):
+        # struct input_event: timeval (ignored on write), type, code, value
+        os.write(self._fd, struct.pack("llHHi", 0, 0, EV_KEY, code, value))
+        os.write(self._fd, struct.pack("llHHi", 0, 0, EV_SYN, SYN_REPORT, 0))
@@ -6,10 +6,37 @@ Entry point for the voice typing engine.
 """
 
 import argparse
+import io
+import json
 import signal
 import sys
 
-from internal import VoiceTyping
+from internal import VoiceTyping, events
+from internal.config import AudioConfig, TranscriptionConfig, VADConfig
+from internal.output import TextOutput
+from internal.transcribe import Transcriber, gpu_available
+
+
+def transcribe_file(args, language):
+    """Transcribe a recording (or stdin for "-") and print the segments as JSON."""
+    def progress(stage, progress=None):
+        events.emit("loading", id=0, stage=stage, progress=progress)
+
+    transcriber = Transcriber(
+        args.model, args.device, language, args.fast, on_progress=progress, warmup=False,
+        beam_size=args.beam_size, min_confidence=args.min_confidence, min_audio_duration=args.min_audio_duration
+    )
+    source = io.BytesIO(sys.stdin.buffer.read()) if args.transcribe == '-' else args.transcribe
+
+    progress("transcribing")
+    try:
+        segments, info = transcriber.transcribe_file(source)
+    except Exception as e:
+        events.fatal(f"failed to transcribe {args.transcribe}: {e}")
+        return 1
+
+    print(json.dumps({**info, "segments": segments}))
+    return 0
 
 
 def main():
@@ -49,6 +76,17 @@ def main():
         action='store_true',
         help='Disable keyboard typing (only print to terminal)'
     )
+    parser.add_argument(
+        '--typing-backends',
+        type=lambda s: s.split(','),
+        metavar='LIST',
+        help='Comma-separated typing backends to try in order: wtype, xdotool, ydotool, dotool, uinput'
+    )
+    parser.add_argument(
+        '--type-test',
+        metavar='TEXT',
+        help='Type TEXT and Enter into the focused window, then exit (status 1 if no backend could)'
+    )
     parser.add_argument(
         '--output-file',
         action='store_true',
@@ -59,48 +97,153 @@ def main():
     parser.add_argument('--cuda', action='store_const', const='gpu', dest='device', help='Use CUDA/GPU (alias for --device gpu)')
     parser.add_argument('--lang', dest='language', help='Language code (alias for --language)')
     parser.add_argument(
-        '--timeout',
+        '--flush-on-stop',
+        action='store_true',
+        help='Transcribe the utterance being recorded when stopping'
+    )
+
+    parser.add_argument(
+        '--capture-mode',
+        default='vad',
+        choices=['vad', 'ptt'],
+        help='Record on detected speech (vad) or between hold and release commands (ptt)'
+    )
+    parser.add_argument(
+        '--silence-duration',
+        type=float,
+        default=AudioConfig.SILENCE_DURATION_SEC,
+        metavar='SECONDS',
+        help=f'Silence that ends an utterance (default: {AudioConfig.SILENCE_DURATION_SEC})'
+    )
+    parser.add_argument(
+        '--pre-buffer',
+        type=float,
+        default=AudioConfig.PRE_BUFFER_DURATION_SEC,
+        metavar='SECONDS',
+        help=f'Audio kept from before speech was detected (default: {AudioConfig.PRE_BUFFER_DURATION_SEC})'
+    )
+    parser.add_argument(
+        '--vad-aggressiveness',
+        type=int,
+        default=VADConfig.AGGRESSIVENESS,
+        choices=[0, 1, 2, 3],
+        help=f'How strictly sound must look like speech (default: {VADConfig.AGGRESSIVENESS})'
+    )
+    parser.add_argument(
+        '--beam-size',
+        type=int,
+        default=TranscriptionConfig.BEAM_SIZE,
+        help=f'Whisper beam size (default: {TranscriptionConfig.BEAM_SIZE})'
+    )
+    parser.add_argument(
+        '--min-confidence',
+        type=float,
+        default=TranscriptionConfig.MIN_CONFIDENCE,
+        metavar='LOGPROB',
+        help=f'Drop live segments with a lower average log-probability (default: {TranscriptionConfig.MIN_CONFIDENCE})'
+    )
+    parser.add_argument(
+        '--min-audio-duration',
+        type=float,
+        default=TranscriptionConfig.MIN_AUDIO_DURATION_SEC,
+        metavar='SECONDS',
+        help=f'Skip shorter utterances (default: {TranscriptionConfig.MIN_AUDIO_DURATION_SEC})'
+    )
+    parser.add_argument(
+        '--app-rules',
+        type=json.loads,
+        metavar='JSON',
+        help='Per-application overrides for the focused window ([[app]] tables of config.toml)'
+    )
+    parser.add_argument(
+        '--once',
         type=int,
         default=0,
-        help='Seconds of no output before auto-pause (0 = disabled)'
+        metavar='SECONDS',
+        help='Capture one utterance (waiting up to SECONDS for speech), report it and exit'
+    )
+    parser.add_argument(
+        '--transcribe',
+        metavar='FILE',
+        help='Transcribe an audio file ("-" for stdin), print segments as JSON and exit'
+    )
+    parser.add_argument(
+        '--event-fd',
+        type=int,
+        help='File descriptor to write supervisor events to (JSON lines)'
+    )
+    parser.add_argument(
+        '--control-fd',
+        type=int,
+        help='File descriptor to read supervisor commands from (JSON lines)'
     )
 
     args = parser.parse_args()
 
+    if args.event_fd is not None:
+        events.open_stream(args.event_fd)
+
+    if args.type_test is not None:
+        output = TextOutput(backends=args.typing_backends)
+        typed = output.send_keys(args.type_test, press_enter=True)
+        output.close()
+        sys.exit(0 if typed else 1)
+
     # Normalize cuda -> gpu (they're aliases)
     if args.device == 'cuda':
         args.device = 'gpu'
 
     # Validate GPU availability
-    if args.device == 'gpu':
-        try:
-            import ctranslate2
-            if ctranslate2.get_cuda_device_count() == 0:
-                print("GPU not available, using CPU")
-                args.device = 'cpu'
-        except Exception:
-            print("GPU not available, using CPU")
-            args.device = 'cpu'
+    if args.device == 'gpu' and not gpu_available():
+        print("GPU not available, using CPU", file=sys.stderr)
+        events.warning("GPU not available, using CPU")
+        args.device = 'cpu'
 
     # Convert "auto" or empty string to None for auto-detect
     language = None if args.language in ["", "auto"] else args.language
 
-    # Create and run engine
-    vt = VoiceTyping(
-        model_size=args.model,
-        device=args.device,
-        language=language,
-        tcp_port=args.tcp,
-        fast=args.fast,
-        enable_typing=not args.no_typing,
-        output_file=args.output_file,
-        timeout=args.timeout
-    )
-
-    # Handle Ctrl+C gracefully
+    if args.transcribe:
+        sys.exit(transcribe_file(args, language))
+
+    # Exit cleanly if stopped during model load (the engine installs graceful handlers once ready)
     signal.signal(signal.SIGINT, lambda _s, _f: sys.exit(0))
+    signal.signal(signal.SIGTERM, lambda _s, _f: sys.exit(0))
+
+    # Create and run engine
+    try:
+        vt = VoiceTyping(
+            model_size=args.model,
+            device=args.device,
+            language=language,
+            tcp_port=args.tcp,
+            fast=args.fast,
+            enable_typing=not args.no_typing,
+            typing_backends=args.typing_backends,
+            output_file=args.output_file,
+            flush_on_stop=args.flush_on_stop,
+            capture_mode=args.capture_mode,
+            control_fd=args.control_fd,
+            once_timeout=args.once,
+            tuning={
+                "silence_duration": args.silence_duration,
+                "pre_buffer": args.pre_buffer,
+                "vad_aggressiveness": args.vad_aggressiveness,
+                "beam_size": args.beam_size,
+                "min_confidence": args.min_confidence,
+                "min_audio_duration": args.min_audio_duration,
+            },
+            app_rules=args.app_rules
+        )
+    except Exception as e:
+        # Surfaces the reason in Go; the traceback still goes to stderr
+        events.fatal(f"failed to start engine: {e}")
+        raise
 
-    vt.run()
+    try:
+        vt.run()
+    except Exception as e:
+        events.fatal(f"engine crashed: {e}")
+        raise
 
 
 if __name__ == '__main__':
@@ -0,0 +1,127 @@
+package internal
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// envPrefix names the environment variables that override config.toml,
+// e.g. YAP_MODEL=small or YAP_TCP_PORT=0.
+const envPrefix = "YAP_"
+
+// Override is a setting given on top of config.toml, e.g. by a start flag.
+type Override struct {
+	Key    string
+	Value  string
+	Source string // shown by yap config show --effective, e.g. "--model"
+}
+
+// EnvVar returns the environment variable that overrides a setting, e.g.
+// YAP_AUDIO_SILENCE_DURATION for audio.silence_duration.
+func EnvVar(key string) string {
+	return envPrefix + strings.ToUpper(strings.ReplaceAll(key, ".", "_"))
+}
+
+// Resolve layers everything else on a config read by ReadConfig, lowest
+// precedence first: defaults < config.toml < the selected profile < YAP_*
+// environment variables < overrides. The profile is picked the same way:
+// an override, then YAP_PROFILE, then profile in config.toml.
+func (c *Config) Resolve(overrides []Override) error {
+	profile, source := c.Profile, ""
+	if env := os.Getenv(EnvVar("profile")); env != "" {
+		profile, source = env, EnvVar("profile")
+	}
+	for _, o := range overrides {
+		if o.Key == "profile" {
+			profile, source = o.Value, o.Source
+		}
+	}
+	if err := c.ApplyProfile(profile); err != nil {
+		return err
+	}
+	if source != "" {
+		c.setSource("profile", source)
+	}
+
+	for _, f := range configFields() {
+		if f.key == "profile" {
+			continue
+		}
+		name := EnvVar(f.key)
+		value, ok := os.LookupEnv(name)
+		if !ok {
+			continue
+		}
+		if err := c.setValue(f, value); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		c.setSource(f.key, name)
+	}
+
+	for _, o := range overrides {
+		if o.Key == "profile" {
+			continue
+		}
+		f, err := lookupConfigField(o.Key)
+		if err != nil {
+			return err
+		}
+		if err := c.setValue(f, o.Value); err != nil {
+			return fmt.Errorf("%s: %w", o.Source, err)
+		}
+		c.setSource(f.key, o.Source)
+	}
+
+	return nil
+}
+
+// Source tells where the current value of a setting came from: "default",
+// "config.toml", "profile <name>", an environment variable or a flag.
+func (c *Config) Source(key string) string {
+	if source, ok := c.sources[key]; ok {
+		return source
+	}
+	if c.meta.IsDefined(strings.Split(key, ".")...) {
+		return "config.toml"
+	}
+	return "default"
+}
+
+func (c *Config) setSource(key, source string) {
+	if c.sources == nil {
+		c.sources = make(map[string]string)
+	}
+	c.sources[key] = source
+}
+
+// setValue parses a setting from text. Booleans also accept 1/0, as usual for
+// environment variables.
+func (c *Config) setValue(f configField, value string) error {
+	field := reflect.ValueOf(c).Elem().FieldByIndex(f.index)
+	switch f.kind {
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("must be true or false, got %q", value)
+		}
+		field.SetBool(b)
+	case reflect.Int:
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("must be a whole number, got %q", value)
+		}
+		field.SetInt(int64(n))
+	case reflect.Float64:
+		x, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return fmt.Errorf("must be a number, got %q", value)
+		}
+		field.SetFloat(x)
+	default:
+		field.SetString(value)
+	}
+	return nil
+}
@@ -18,7 +18,7 @@ var pythonFiles embed.FS
 var defaultConfig []byte
 
 const (
-	appDirName = "yappers-of-linux" // used under both XDG_CONFIG_HOME and XDG_DATA_HOME
+	appDirName = "yappers-of-linux" // used under XDG_CONFIG_HOME, XDG_DATA_HOME and XDG_STATE_HOME
 )
 
 func verifyPythonFiles() bool {
@@ -72,6 +72,10 @@ func SelfHeal() error {
 		return fmt.Errorf("failed to extract config file: %w", err)
 	}
 
+	if err := MigrateConfig(); err != nil {
+		return fmt.Errorf("failed to migrate config file: %w", err)
+	}
+
 	if !verifyPythonFiles() {
 		if err := extractPythonFiles(); err != nil {
 			return fmt.Errorf("failed to extract python files: %w", err)
@@ -111,6 +115,17 @@ func GetSystemDir() (string, error) {
 	return filepath.Join(homeDir, ".local", "share", appDirName), nil
 }
 
+func GetStateDir() (string, error) {
+	if xdg := os.Getenv("XDG_STATE_HOME"); xdg != "" {
+		return filepath.Join(xdg, appDirName), nil
+	}
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(homeDir, ".local", "state", appDirName), nil
+}
+
 func ensureConfigDir() error {
 	configDir, err := GetConfigDir()
 	if err != nil {
@@ -0,0 +1,108 @@
+package internal
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const ServiceName = "yap.service"
+
+// sessionEnvVars are copied into the unit file; the typing backends and
+// notifications need them, and the systemd user manager does not always have them.
+var sessionEnvVars = []string{
+	"XDG_SESSION_TYPE",
+	"XDG_CURRENT_DESKTOP",
+	"WAYLAND_DISPLAY",
+	"DISPLAY",
+	"XAUTHORITY",
+	"DBUS_SESSION_BUS_ADDRESS",
+	"YDOTOOL_SOCKET",
+	"PATH",
+}
+
+func GetServiceFile() (string, error) {
+	configHome := os.Getenv("XDG_CONFIG_HOME")
+	if configHome == "" {
+		homeDir, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		configHome = filepath.Join(homeDir, ".config")
+	}
+	return filepath.Join(configHome, "systemd", "user", ServiceName), nil
+}
+
+// ServiceUnit renders a systemd user unit that runs `exe start <args>` in the foreground
+// with the current graphical session's environment. stopTimeout is how long
+// yap may take to stop before systemd kills it.
+func ServiceUnit(exe string, args []string, stopTimeout time.Duration) string {
+	execStart := []string{quoteUnitArg(exe), "start"}
+	for _, arg := range args {
+		execStart = append(execStart, quoteUnitArg(arg))
+	}
+
+	var b strings.Builder
+	b.WriteString("# Generated by `yap service install`, changes are overwritten on reinstall\n")
+	b.WriteString("[Unit]\n")
+	b.WriteString("Description=Yappers of Linux voice typing\n")
+	b.WriteString("After=graphical-session.target pipewire.service pulseaudio.service\n")
+	b.WriteString("PartOf=graphical-session.target\n")
+	b.WriteString("\n[Service]\n")
+	b.WriteString("Type=simple\n")
+	b.WriteString("ExecStart=" + strings.Join(execStart, " ") + "\n")
+	b.WriteString("ExecReload=/bin/kill -HUP $MAINPID\n")
+	b.WriteString("Restart=on-failure\n")
+	b.WriteString("RestartSec=5\n")
+	// SIGTERM to yap alone, which stops the engine with a flush; a second
+	// SIGTERM straight from systemd would make the engine give up on it
+	b.WriteString("KillMode=mixed\n")
+	b.WriteString(fmt.Sprintf("TimeoutStopSec=%d\n", int(stopTimeout.Seconds())))
+	for _, name := range sessionEnvVars {
+		if value := os.Getenv(name); value != "" {
+			b.WriteString("Environment=" + quoteUnitEnv(name+"="+value) + "\n")
+		}
+	}
+	b.WriteString("\n[Install]\n")
+	b.WriteString("WantedBy=graphical-session.target\n")
+
+	return b.String()
+}
+
+// IsSystemdService reports whether this process was started by systemd as yap.service.
+func IsSystemdService() bool {
+	if os.Getenv("INVOCATION_ID") == "" {
+		return false
+	}
+	cgroup, err := os.ReadFile("/proc/self/cgroup")
+	if err != nil {
+		return false
+	}
+	return strings.Contains(string(cgroup), "/"+ServiceName)
+}
+
+// quoteUnitArg quotes an ExecStart argument.
+func quoteUnitArg(s string) string {
+	if s != "" && !strings.ContainsAny(s, " \t\"'\\$%;") {
+		return s
+	}
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, `"`, `\"`)
+	s = strings.ReplaceAll(s, "$", "$$")
+	s = strings.ReplaceAll(s, "%", "%%")
+	return `"` + s + `"`
+}
+
+// quoteUnitEnv quotes an Environment= assignment, where unlike in ExecStart a
+// $ is taken literally.
+func quoteUnitEnv(s string) string {
+	if !strings.ContainsAny(s, " \t\"'\\%") {
+		return s
+	}
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, `"`, `\"`)
+	s = strings.ReplaceAll(s, "%", "%%")
+	return `"` + s + `"`
+}
@@ -0,0 +1,121 @@
+package internal
+
+import (
+	"embed"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+)
+
+// Cues are 16-bit mono PCM WAVs, one per event
+//
+//go:embed examples/sounds/*.wav
+var soundFiles embed.FS
+
+// SoundEvents are the events of the [sounds] table.
+var SoundEvents = []string{"ready", "pause", "stop", "captured", "error"}
+
+// soundPlayers are tried in order; each plays the file given as last argument.
+var soundPlayers = [][]string{
+	{"pw-play"},
+	{"paplay"},
+	{"aplay", "-q"},
+}
+
+// plays reports whether event's cue is switched on.
+func (s SoundSettings) plays(event string) bool {
+	if !s.Enabled || s.Volume <= 0 {
+		return false
+	}
+	switch event {
+	case "ready":
+		return s.Ready
+	case "pause":
+		return s.Pause
+	case "stop":
+		return s.Stop
+	case "captured":
+		return s.Captured
+	case "error":
+		return s.Error
+	}
+	return false
+}
+
+// SoundPlayer returns the first available player command, or nil.
+func SoundPlayer() []string {
+	for _, player := range soundPlayers {
+		if HasCommand(player[0]) {
+			return player
+		}
+	}
+	return nil
+}
+
+// PlaySound plays the cue for event in the background if it is switched on.
+func PlaySound(event string, s SoundSettings) {
+	if !s.plays(event) {
+		return
+	}
+	player := SoundPlayer()
+	if player == nil {
+		return
+	}
+	path, err := soundFile(event, min(s.Volume, 1))
+	if err != nil {
+		return
+	}
+
+	cmd := exec.Command(player[0], append(player[1:], path)...)
+	if err := cmd.Start(); err == nil {
+		go cmd.Wait()
+	}
+}
+
+// soundFile writes the cue at the given volume to the runtime directory, once.
+// Volume is applied to the samples, so it works the same with every player,
+// and the file outlives yap, so a stop cue can finish after it exits.
+func soundFile(event string, volume float64) (string, error) {
+	percent := int(volume * 100)
+	dir := filepath.Join(GetRuntimeDir(), "yap-sounds")
+	path := filepath.Join(dir, fmt.Sprintf("%s-%d.wav", event, percent))
+	if _, err := os.Stat(path); err == nil {
+		return path, nil
+	}
+
+	data, err := soundFiles.ReadFile("examples/sounds/" + event + ".wav")
+	if err != nil {
+		return "", err
+	}
+	scaleWAV(data, float64(percent)/100)
+
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return "", err
+	}
+	return path, os.Rename(tmp, path)
+}
+
+// scaleWAV multiplies the 16-bit samples of a WAV file's data chunk in place.
+func scaleWAV(data []byte, volume float64) {
+	// RIFF header, then chunks of a 4-byte id and a 4-byte size
+	for i := 12; i+8 <= len(data); {
+		id := string(data[i : i+4])
+		size := int(binary.LittleEndian.Uint32(data[i+4 : i+8]))
+		i += 8
+		if id != "data" {
+			i += size + size%2
+			continue
+		}
+		for j := i; j+2 <= min(i+size, len(data)); j += 2 {
+			sample := float64(int16(binary.LittleEndian.Uint16(data[j:]))) * volume
+			binary.LittleEndian.PutUint16(data[j:], uint16(int16(sample)))
+		}
+		return
+	}
+}
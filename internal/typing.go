@@ -0,0 +1,152 @@
+package internal
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"syscall"
+)
+
+const uinputDevice = "/dev/uinput"
+
+// TypingBackend is a tool the engine can type with (see output.py, which
+// implements each one). Check tells, before starting, whether it can work.
+type TypingBackend struct {
+	Name     string
+	Sessions []string // session types auto tries it on; nil for any
+	Summary  string
+	Check    func() error
+}
+
+// TypingBackends are in the order auto tries them.
+var TypingBackends = []TypingBackend{
+	{Name: "wtype", Sessions: []string{"wayland"}, Summary: "Wayland, wlroots compositors (Sway, Hyprland, river)", Check: needCommand("wtype")},
+	{Name: "xdotool", Sessions: []string{"x11"}, Summary: "X11", Check: needCommand("xdotool")},
+	{Name: "ydotool", Summary: "Any session, through the ydotoold daemon", Check: checkYdotool},
+	{Name: "dotool", Summary: "Any session, needs " + uinputDevice + " access", Check: checkDotool},
+	{Name: "uinput", Summary: "Built in, ASCII on a US layout, needs " + uinputDevice, Check: checkUinput},
+}
+
+// TypingBackendNames are the values typing_backend takes, alone or as a
+// comma-separated list.
+func TypingBackendNames() []string {
+	names := []string{"auto"}
+	for _, b := range TypingBackends {
+		names = append(names, b.Name)
+	}
+	return names
+}
+
+// LookupTypingBackend returns the backend called name, or nil.
+func LookupTypingBackend(name string) *TypingBackend {
+	for i := range TypingBackends {
+		if TypingBackends[i].Name == name {
+			return &TypingBackends[i]
+		}
+	}
+	return nil
+}
+
+// SessionType is XDG_SESSION_TYPE: "wayland", "x11" or "" if unknown.
+func SessionType() string {
+	session := strings.ToLower(os.Getenv("XDG_SESSION_TYPE"))
+	if session != "wayland" && session != "x11" {
+		return ""
+	}
+	return session
+}
+
+// TypingCandidates returns the backends setting names, in order. "auto" is
+// every backend that suits the session; unknown names are left out.
+func TypingCandidates(setting string) []*TypingBackend {
+	var candidates []*TypingBackend
+	add := func(b *TypingBackend) {
+		if !slices.Contains(candidates, b) {
+			candidates = append(candidates, b)
+		}
+	}
+
+	session := SessionType()
+	for _, name := range strings.Split(setting, ",") {
+		name = strings.TrimSpace(name)
+		if name != "auto" && name != "" {
+			if b := LookupTypingBackend(name); b != nil {
+				add(b)
+			}
+			continue
+		}
+		for i := range TypingBackends {
+			b := &TypingBackends[i]
+			if b.Sessions == nil || session == "" || IsOneOf(session, b.Sessions) {
+				add(b)
+			}
+		}
+	}
+	return candidates
+}
+
+// TypingChain returns the backends of setting that pass their check, which
+// the engine tries in order until one types. It errors if none does,
+// saying what each one is missing.
+func TypingChain(setting string) ([]string, error) {
+	var chain, problems []string
+	for _, b := range TypingCandidates(setting) {
+		if err := b.Check(); err != nil {
+			problems = append(problems, fmt.Sprintf("  %s: %v", b.Name, err))
+			continue
+		}
+		chain = append(chain, b.Name)
+	}
+	if len(chain) == 0 {
+		return nil, fmt.Errorf("no typing backend available (typing_backend = %q):\n%s", setting, strings.Join(problems, "\n"))
+	}
+	return chain, nil
+}
+
+func needCommand(name string) func() error {
+	return func() error {
+		if !HasCommand(name) {
+			return fmt.Errorf("%s not found", name)
+		}
+		return nil
+	}
+}
+
+// checkYdotool wants the ydotoold socket, without which ydotool only prints
+// an error.
+func checkYdotool() error {
+	if !HasCommand("ydotool") {
+		return fmt.Errorf("ydotool not found")
+	}
+	// Where ydotoold puts it, unless told otherwise
+	sockets := []string{"/tmp/.ydotool_socket"}
+	if runtime := os.Getenv("XDG_RUNTIME_DIR"); runtime != "" {
+		sockets = []string{filepath.Join(runtime, ".ydotool_socket"), "/tmp/.ydotool_socket"}
+	}
+	if socket := os.Getenv("YDOTOOL_SOCKET"); socket != "" {
+		sockets = []string{socket}
+	}
+	for _, socket := range sockets {
+		if info, err := os.Stat(socket); err == nil && info.Mode()&os.ModeSocket != 0 {
+			return nil
+		}
+	}
+	return fmt.Errorf("ydotoold is not running (no socket at %s)", strings.Join(sockets, " or "))
+}
+
+func checkDotool() error {
+	if !HasCommand("dotool") {
+		return fmt.Errorf("dotool not found")
+	}
+	return checkUinput()
+}
+
+func checkUinput() error {
+	const writable = 2 // W_OK
+	if err := syscall.Access(uinputDevice, writable); err != nil {
+		return fmt.Errorf("cannot write %s (%v); add yourself to the input group or a udev rule", uinputDevice, err)
+	}
+	return nil
+}